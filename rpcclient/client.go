@@ -15,9 +15,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"strconv"
 
 	"github.com/flashbots/go-utils/signature"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 const (
@@ -195,6 +197,9 @@ func NewRequestWithObjectParam(id int, method string, params any) *RPCRequest {
 //
 // Result: holds the result of the rpc call if no error occurred, nil otherwise. can be nil even on success.
 //
+// RawResult: holds the result exactly as it was received from the server, nil if response was not received from the server.
+// Result is not decoded for the calls made with typed helpers (Call, CallBatch), RawResult is always set.
+//
 // Error: holds an RPCError object if an error occurred. must be nil on success.
 //
 // ID: may always be 0 for single requests. is unique for each request in a batch call (see CallBatch())
@@ -207,6 +212,64 @@ type RPCResponse struct {
 	Result  any       `json:"result,omitempty"`
 	Error   *RPCError `json:"error,omitempty"`
 	ID      int       `json:"id"`
+
+	// RawResult is not updated when Result is changed and is not used for encoding
+	RawResult json.RawMessage `json:"-"`
+}
+
+// wireResponse is RPCResponse as received from the server with undecoded result and error data
+type wireResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *wireError      `json:"error,omitempty"`
+	ID      int             `json:"id"`
+}
+
+type wireError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// toRPCResponse converts wire response, Result is decoded only if decodeResult is true
+func (r *wireResponse) toRPCResponse(decodeResult bool) (*RPCResponse, error) {
+	if r == nil {
+		return nil, nil
+	}
+	response := &RPCResponse{
+		JSONRPC:   r.JSONRPC,
+		ID:        r.ID,
+		RawResult: r.Result,
+	}
+	if decodeResult && len(r.Result) > 0 {
+		if err := decodeWithNumbers(r.Result, &response.Result); err != nil {
+			return nil, err
+		}
+	}
+	if r.Error != nil {
+		response.Error = r.Error.toRPCError()
+	}
+	return response, nil
+}
+
+// toRPCError converts wire error, data is valid JSON decoded by the response decoder so it is always decoded into Data
+func (e *wireError) toRPCError() *RPCError {
+	rpcErr := &RPCError{
+		Code:    e.Code,
+		Message: e.Message,
+		RawData: e.Data,
+	}
+	if len(e.Data) > 0 {
+		_ = decodeWithNumbers(e.Data, &rpcErr.Data)
+	}
+	return rpcErr
+}
+
+// decodeWithNumbers decodes data keeping numbers as json.Number
+func decodeWithNumbers(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
 }
 
 // RPCError represents a JSON-RPC error object if an RPC error occurred.
@@ -217,11 +280,16 @@ type RPCResponse struct {
 //
 // Data holds additional error data, may be nil.
 //
+// RawData holds the data exactly as it was received from the server, may be nil.
+//
 // See: http://www.jsonrpc.org/specification#error_object
 type RPCError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 	Data    any    `json:"data,omitempty"`
+
+	// RawData is not updated when Data is changed and is not used for encoding
+	RawData json.RawMessage `json:"-"`
 }
 
 // Error function is provided to be used as error object.
@@ -253,6 +321,8 @@ type rpcClient struct {
 	defaultRequestID            int
 	signer                      *signature.Signer
 	rejectBrokenFlashbotsErrors bool
+	compression                 string
+	retryPolicy                 *RetryPolicy
 }
 
 // RPCClientOpts can be provided to NewClientWithOpts() to change configuration of RPCClient.
@@ -274,6 +344,11 @@ type RPCClientOpts struct {
 	// otherwise this response will be converted to equivalent {"error": {"message": "text", "code": FlashbotsBrokenErrorResponseCode}}
 	// Bad errors are always rejected for batch requests
 	RejectBrokenFlashbotsErrors bool
+	// If set to CompressionGzip or CompressionZstd request body is compressed and compressed responses are accepted.
+	// Signature is created for the uncompressed body.
+	Compression string
+	// If set failed calls are retried, every attempt is signed again
+	RetryPolicy *RetryPolicy
 }
 
 // RPCResponses is of type []*RPCResponse.
@@ -355,17 +430,26 @@ func NewClientWithOpts(endpoint string, opts *RPCClientOpts) RPCClient {
 	rpcClient.defaultRequestID = opts.DefaultRequestID
 	rpcClient.signer = opts.Signer
 	rpcClient.rejectBrokenFlashbotsErrors = opts.RejectBrokenFlashbotsErrors
+	rpcClient.compression = opts.Compression
+	rpcClient.retryPolicy = opts.RetryPolicy
 
 	return rpcClient
 }
 
 func (client *rpcClient) Call(ctx context.Context, method string, params ...any) (*RPCResponse, error) {
-	request := NewRequestWithID(client.defaultRequestID, method, params...)
-	return client.doCall(ctx, request)
+	return client.doCall(ctx, client.newCallRequest(method, params...), true)
 }
 
 func (client *rpcClient) CallRaw(ctx context.Context, request *RPCRequest) (*RPCResponse, error) {
-	return client.doCall(ctx, request)
+	return client.doCall(ctx, request, true)
+}
+
+func (client *rpcClient) newCallRequest(method string, params ...any) *RPCRequest {
+	return NewRequestWithID(client.defaultRequestID, method, params...)
+}
+
+func (client *rpcClient) callRaw(ctx context.Context, request *RPCRequest, decodeResult bool) (*RPCResponse, error) {
+	return client.doCall(ctx, request, decodeResult)
 }
 
 func (client *rpcClient) CallFor(ctx context.Context, out any, method string, params ...any) error {
@@ -391,15 +475,19 @@ func (client *rpcClient) CallBatch(ctx context.Context, requests RPCRequests) (R
 		req.JSONRPC = jsonrpcVersion
 	}
 
-	return client.doBatchCall(ctx, requests)
+	return client.doBatchCall(ctx, requests, true)
 }
 
 func (client *rpcClient) CallBatchRaw(ctx context.Context, requests RPCRequests) (RPCResponses, error) {
+	return client.callBatchRaw(ctx, requests, true)
+}
+
+func (client *rpcClient) callBatchRaw(ctx context.Context, requests RPCRequests, decodeResult bool) (RPCResponses, error) {
 	if len(requests) == 0 {
 		return nil, errors.New("empty request list")
 	}
 
-	return client.doBatchCall(ctx, requests)
+	return client.doBatchCall(ctx, requests, decodeResult)
 }
 
 func (client *rpcClient) newRequest(ctx context.Context, req any) (*http.Request, error) {
@@ -408,6 +496,21 @@ func (client *rpcClient) newRequest(ctx context.Context, req any) (*http.Request
 		return nil, err
 	}
 
+	var signatureHeader string
+	if client.signer != nil {
+		signatureHeader, err = client.signer.Create(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if client.compression != "" {
+		body, err = compressBody(body, client.compression)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	request, err := http.NewRequestWithContext(ctx, "POST", client.endpoint, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
@@ -416,14 +519,18 @@ func (client *rpcClient) newRequest(ctx context.Context, req any) (*http.Request
 	request.Header.Set("Content-Type", "application/json")
 	request.Header.Set("Accept", "application/json")
 
-	if client.signer != nil {
-		signatureHeader, err := client.signer.Create(body)
-		if err != nil {
-			return nil, err
-		}
+	if signatureHeader != "" {
 		request.Header.Set(signature.HTTPHeader, signatureHeader)
 	}
 
+	if client.compression != "" {
+		request.Header.Set("Content-Encoding", client.compression)
+		request.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	// W3C traceparent header is set if context has OpenTelemetry span
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(request.Header))
+
 	// set default headers first, so that even content type and accept can be overwritten
 	for k, v := range client.customHeaders {
 		// check if header is "Host" since this will be set on the request struct itself
@@ -437,20 +544,41 @@ func (client *rpcClient) newRequest(ctx context.Context, req any) (*http.Request
 	return request, nil
 }
 
-func (client *rpcClient) doCall(ctx context.Context, RPCRequest *RPCRequest) (*RPCResponse, error) {
+func (client *rpcClient) doCall(ctx context.Context, request *RPCRequest, decodeResult bool) (*RPCResponse, error) {
+	// JSON-RPC response is returned without error for any status code, so the status is checked by the retry policy
+	var statusCode int
+	return withRetry(ctx, client.retryPolicy, request.Method, []string{request.Method},
+		func() (response *RPCResponse, err error) {
+			response, statusCode, err = client.doCallOnce(ctx, request, decodeResult)
+			return response, err
+		},
+		func(response *RPCResponse) bool {
+			return client.retryPolicy.isRetryableResponse(response) || client.retryPolicy.isRetryableHTTPCode(statusCode)
+		},
+	)
+}
+
+// doCallOnce makes the call and returns the response with the HTTP status code, status code is 0 if request failed
+func (client *rpcClient) doCallOnce(ctx context.Context, RPCRequest *RPCRequest, decodeResult bool) (*RPCResponse, int, error) {
 	httpRequest, err := client.newRequest(ctx, RPCRequest)
 	if err != nil {
-		return nil, fmt.Errorf("rpc call %v() on %v: %w", RPCRequest.Method, client.endpoint, err)
+		return nil, 0, fmt.Errorf("rpc call %v() on %v: %w", RPCRequest.Method, client.endpoint, err)
 	}
 	httpResponse, err := client.httpClient.Do(httpRequest)
 	if err != nil {
-		return nil, fmt.Errorf("rpc call %v() on %v: %w", RPCRequest.Method, httpRequest.URL.Redacted(), err)
+		return nil, 0, fmt.Errorf("rpc call %v() on %v: %w", RPCRequest.Method, httpRequest.URL.Redacted(), err)
 	}
 	defer httpResponse.Body.Close()
 
-	body, err := io.ReadAll(httpResponse.Body)
+	responseReader, err := decompressResponseBody(httpResponse)
+	if err != nil {
+		return nil, 0, fmt.Errorf("rpc call %v() on %v: %w", RPCRequest.Method, httpRequest.URL.Redacted(), err)
+	}
+	defer responseReader.Close()
+
+	body, err := io.ReadAll(responseReader)
 	if err != nil {
-		return nil, fmt.Errorf("rpc call %v() on %v: %w", RPCRequest.Method, httpRequest.URL.Redacted(), err)
+		return nil, 0, fmt.Errorf("rpc call %v() on %v: %w", RPCRequest.Method, httpRequest.URL.Redacted(), err)
 	}
 
 	decodeJSONBody := func(v any) error {
@@ -463,38 +591,57 @@ func (client *rpcClient) doCall(ctx context.Context, RPCRequest *RPCRequest) (*R
 	}
 
 	var (
+		wire        *wireResponse
 		rpcResponse *RPCResponse
 	)
-	err = decodeJSONBody(&rpcResponse)
+	err = decodeJSONBody(&wire)
+	if err == nil {
+		rpcResponse, err = wire.toRPCResponse(decodeResult)
+	}
 
 	// parsing error
 	if err != nil {
 		// if we have some http error, return it
 		if httpResponse.StatusCode >= 400 {
-			return nil, &HTTPError{
+			return nil, httpResponse.StatusCode, &HTTPError{
 				Code: httpResponse.StatusCode,
 				err:  fmt.Errorf("rpc call %v() on %v status code: %v. could not decode body to rpc response: %w", RPCRequest.Method, httpRequest.URL.Redacted(), httpResponse.StatusCode, err),
 			}
 		}
-		return nil, fmt.Errorf("rpc call %v() on %v status code: %v. could not decode body to rpc response: %w", RPCRequest.Method, httpRequest.URL.Redacted(), httpResponse.StatusCode, err)
+		return nil, httpResponse.StatusCode, fmt.Errorf("rpc call %v() on %v status code: %v. could not decode body to rpc response: %w", RPCRequest.Method, httpRequest.URL.Redacted(), httpResponse.StatusCode, err)
 	}
 
 	// response body empty
 	if rpcResponse == nil {
 		// if we have some http error, return it
 		if httpResponse.StatusCode >= 400 {
-			return nil, &HTTPError{
+			return nil, httpResponse.StatusCode, &HTTPError{
 				Code: httpResponse.StatusCode,
 				err:  fmt.Errorf("rpc call %v() on %v status code: %v. rpc response missing", RPCRequest.Method, httpRequest.URL.Redacted(), httpResponse.StatusCode),
 			}
 		}
-		return nil, fmt.Errorf("rpc call %v() on %v status code: %v. rpc response missing", RPCRequest.Method, httpRequest.URL.Redacted(), httpResponse.StatusCode)
+		return nil, httpResponse.StatusCode, fmt.Errorf("rpc call %v() on %v status code: %v. rpc response missing", RPCRequest.Method, httpRequest.URL.Redacted(), httpResponse.StatusCode)
 	}
 
-	return rpcResponse, nil
+	return rpcResponse, httpResponse.StatusCode, nil
 }
 
-func (client *rpcClient) doBatchCall(ctx context.Context, rpcRequest []*RPCRequest) ([]*RPCResponse, error) {
+func (client *rpcClient) doBatchCall(ctx context.Context, rpcRequest []*RPCRequest, decodeResult bool) ([]*RPCResponse, error) {
+	methods := make([]string, 0, len(rpcRequest))
+	for _, request := range rpcRequest {
+		methods = append(methods, request.Method)
+	}
+	return withRetry(ctx, client.retryPolicy, batchMethodLabel, methods,
+		func() ([]*RPCResponse, error) {
+			return client.doBatchCallOnce(ctx, rpcRequest, decodeResult)
+		},
+		func(responses []*RPCResponse) bool {
+			return slices.ContainsFunc(responses, client.retryPolicy.isRetryableResponse)
+		},
+	)
+}
+
+func (client *rpcClient) doBatchCallOnce(ctx context.Context, rpcRequest []*RPCRequest, decodeResult bool) ([]*RPCResponse, error) {
 	httpRequest, err := client.newRequest(ctx, rpcRequest)
 	if err != nil {
 		return nil, fmt.Errorf("rpc batch call on %v: %w", client.endpoint, err)
@@ -505,13 +652,29 @@ func (client *rpcClient) doBatchCall(ctx context.Context, rpcRequest []*RPCReque
 	}
 	defer httpResponse.Body.Close()
 
-	var rpcResponses RPCResponses
-	decoder := json.NewDecoder(httpResponse.Body)
+	responseReader, err := decompressResponseBody(httpResponse)
+	if err != nil {
+		return nil, fmt.Errorf("rpc batch call on %v: %w", httpRequest.URL.Redacted(), err)
+	}
+	defer responseReader.Close()
+
+	var (
+		wires        []*wireResponse
+		rpcResponses RPCResponses
+	)
+	decoder := json.NewDecoder(responseReader)
 	if !client.allowUnknownFields {
 		decoder.DisallowUnknownFields()
 	}
-	decoder.UseNumber()
-	err = decoder.Decode(&rpcResponses)
+	err = decoder.Decode(&wires)
+	for _, wire := range wires {
+		if err != nil {
+			break
+		}
+		var rpcResponse *RPCResponse
+		rpcResponse, err = wire.toRPCResponse(decodeResult)
+		rpcResponses = append(rpcResponses, rpcResponse)
+	}
 
 	// parsing error
 	if err != nil {
@@ -552,9 +715,10 @@ func (client *rpcClient) doBatchCall(ctx context.Context, rpcRequest []*RPCReque
 //
 // If result was not an integer an error is returned.
 func (RPCResponse *RPCResponse) GetInt() (int64, error) {
-	val, ok := RPCResponse.Result.(json.Number)
+	result := RPCResponse.result()
+	val, ok := result.(json.Number)
 	if !ok {
-		return 0, fmt.Errorf("could not parse int64 from %s", RPCResponse.Result)
+		return 0, fmt.Errorf("could not parse int64 from %s", result)
 	}
 
 	i, err := val.Int64()
@@ -569,9 +733,10 @@ func (RPCResponse *RPCResponse) GetInt() (int64, error) {
 //
 // If result was not an float64 an error is returned.
 func (RPCResponse *RPCResponse) GetFloat() (float64, error) {
-	val, ok := RPCResponse.Result.(json.Number)
+	result := RPCResponse.result()
+	val, ok := result.(json.Number)
 	if !ok {
-		return 0, fmt.Errorf("could not parse float64 from %s", RPCResponse.Result)
+		return 0, fmt.Errorf("could not parse float64 from %s", result)
 	}
 
 	f, err := val.Float64()
@@ -586,9 +751,10 @@ func (RPCResponse *RPCResponse) GetFloat() (float64, error) {
 //
 // If result was not a bool an error is returned.
 func (RPCResponse *RPCResponse) GetBool() (bool, error) {
-	val, ok := RPCResponse.Result.(bool)
+	result := RPCResponse.result()
+	val, ok := result.(bool)
 	if !ok {
-		return false, fmt.Errorf("could not parse bool from %s", RPCResponse.Result)
+		return false, fmt.Errorf("could not parse bool from %s", result)
 	}
 
 	return val, nil
@@ -598,9 +764,10 @@ func (RPCResponse *RPCResponse) GetBool() (bool, error) {
 //
 // If result was not a string an error is returned.
 func (RPCResponse *RPCResponse) GetString() (string, error) {
-	val, ok := RPCResponse.Result.(string)
+	result := RPCResponse.result()
+	val, ok := result.(string)
 	if !ok {
-		return "", fmt.Errorf("could not parse string from %s", RPCResponse.Result)
+		return "", fmt.Errorf("could not parse string from %s", result)
 	}
 
 	return val, nil
@@ -610,6 +777,11 @@ func (RPCResponse *RPCResponse) GetString() (string, error) {
 //
 // The function works as you would expect it from json.Unmarshal()
 func (RPCResponse *RPCResponse) GetObject(toType any) error {
+	// raw result is decoded directly to avoid encoding Result again
+	if RPCResponse.RawResult != nil {
+		return json.Unmarshal(RPCResponse.RawResult, toType)
+	}
+
 	js, err := json.Marshal(RPCResponse.Result)
 	if err != nil {
 		return err
@@ -622,3 +794,15 @@ func (RPCResponse *RPCResponse) GetObject(toType any) error {
 
 	return nil
 }
+
+// result returns Result or decodes RawResult if Result was not decoded
+func (RPCResponse *RPCResponse) result() any {
+	if RPCResponse.Result != nil || len(RPCResponse.RawResult) == 0 {
+		return RPCResponse.Result
+	}
+	var result any
+	if err := decodeWithNumbers(RPCResponse.RawResult, &result); err != nil {
+		return nil
+	}
+	return result
+}
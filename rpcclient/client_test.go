@@ -1,7 +1,10 @@
 package rpcclient
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,7 +12,9 @@ import (
 	"os"
 	"testing"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/flashbots/go-utils/signature"
 )
@@ -975,6 +980,61 @@ func TestRpcClient_CallFor(t *testing.T) {
 	check.Equal(3, i)
 }
 
+func TestRpcJsonResponseRawResult(t *testing.T) {
+	check := assert.New(t)
+
+	rpcClient := NewClient(httpServer.URL)
+
+	oldResponseBody := responseBody
+	defer func() {
+		responseBody = oldResponseBody
+	}()
+
+	// raw result keeps exact number formatting
+	responseBody = `{"result":{"value":1.50,"big":123456789012345678901234567890},"id":0,"jsonrpc":"2.0"}`
+	res, err := rpcClient.Call(context.Background(), "something")
+	<-requestChan
+	check.Nil(err)
+	check.Equal(`{"value":1.50,"big":123456789012345678901234567890}`, string(res.RawResult))
+	check.Equal(map[string]any{"value": json.Number("1.50"), "big": json.Number("123456789012345678901234567890")}, res.Result)
+
+	var object struct {
+		Big json.Number `json:"big"`
+	}
+	check.Nil(res.GetObject(&object))
+	check.Equal(json.Number("123456789012345678901234567890"), object.Big)
+
+	// getters decode raw result if result was not decoded
+	responseBody = `{"result":12,"id":0,"jsonrpc":"2.0"}`
+	res, err = rpcClient.(rawResultClient).callRaw(context.Background(), NewRequest("something"), false)
+	<-requestChan
+	check.Nil(err)
+	check.Nil(res.Result)
+	i, err := res.GetInt()
+	check.Nil(err)
+	check.Equal(int64(12), i)
+
+	// raw error data is kept for single and batch calls
+	responseBody = `{"error":{"code":1,"message":"failed","data":{"gas":100}},"id":0,"jsonrpc":"2.0"}`
+	res, err = rpcClient.Call(context.Background(), "something")
+	<-requestChan
+	check.Nil(err)
+	check.Equal(`{"gas":100}`, string(res.Error.RawData))
+	check.Equal(map[string]any{"gas": json.Number("100")}, res.Error.Data)
+
+	responseBody = `[{"error":{"code":1,"message":"failed","data":100},"id":0,"jsonrpc":"2.0"}]`
+	responses, err := rpcClient.CallBatch(context.Background(), RPCRequests{NewRequest("something")})
+	<-requestChan
+	check.Nil(err)
+	check.Equal(`100`, string(responses[0].Error.RawData))
+	check.Equal(json.Number("100"), responses[0].Error.Data)
+
+	// raw fields are not encoded
+	encoded, err := json.Marshal(res)
+	check.Nil(err)
+	check.JSONEq(`{"jsonrpc":"2.0","error":{"code":1,"message":"failed","data":{"gas":100}},"id":0}`, string(encoded))
+}
+
 func TestErrorHandling(t *testing.T) {
 	check := assert.New(t)
 	rpcClient := NewClient(httpServer.URL)
@@ -1036,6 +1096,54 @@ func TestUnsignedRequest(t *testing.T) {
 	check.Equal("", header)
 }
 
+func TestCompressedRequest(t *testing.T) {
+	signer, err := signature.NewRandomSigner()
+	require.NoError(t, err)
+
+	decompress := map[string]func(body []byte) ([]byte, error){
+		CompressionGzip: func(body []byte) ([]byte, error) {
+			reader, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				return nil, err
+			}
+			return io.ReadAll(reader)
+		},
+		CompressionZstd: func(body []byte) ([]byte, error) {
+			decoder, err := zstd.NewReader(nil)
+			if err != nil {
+				return nil, err
+			}
+			return decoder.DecodeAll(body, nil)
+		},
+	}
+
+	for compression, decompressBody := range decompress {
+		t.Run(compression, func(t *testing.T) {
+			responseBody = `{"result": 1}`
+			rpcClient := NewClientWithOpts(httpServer.URL, &RPCClientOpts{
+				Signer:      signer,
+				Compression: compression,
+			})
+
+			res, err := rpcClient.Call(context.Background(), "something", 1, 2, 3)
+			reqObject := <-requestChan
+			require.NoError(t, err)
+			require.NotNil(t, res)
+			require.Equal(t, compression, reqObject.request.Header.Get("Content-Encoding"))
+			require.Equal(t, "zstd, gzip", reqObject.request.Header.Get("Accept-Encoding"))
+
+			body, err := decompressBody([]byte(reqObject.body))
+			require.NoError(t, err)
+			require.JSONEq(t, `{"jsonrpc":"2.0","id":0,"method":"something","params":[1,2,3]}`, string(body))
+
+			// signature is created for the uncompressed body
+			recoveredAddress, err := signature.Verify(reqObject.request.Header.Get(signature.HTTPHeader), body)
+			require.NoError(t, err)
+			require.Equal(t, signer.Address(), recoveredAddress)
+		})
+	}
+}
+
 func TestCallFlashbots(t *testing.T) {
 	check := assert.New(t)
 	signer, _ := signature.NewRandomSigner()
@@ -1143,3 +1251,95 @@ func BenchmarkJSONRPCClientWithSignatures(b *testing.B) {
 		}
 	}
 }
+
+// largeResultBody returns response with the block-like result of n transactions
+func largeResultBody(n int) string {
+	txs := make([]map[string]any, 0, n)
+	for i := 0; i < n; i++ {
+		txs = append(txs, map[string]any{
+			"hash":     fmt.Sprintf("0x%064x", i),
+			"from":     fmt.Sprintf("0x%040x", i),
+			"to":       fmt.Sprintf("0x%040x", i+1),
+			"nonce":    fmt.Sprintf("0x%x", i),
+			"value":    "0xde0b6b3a7640000",
+			"gas":      21000,
+			"gasPrice": 1000000000,
+			"input":    "0x",
+		})
+	}
+	result, _ := json.Marshal(map[string]any{"number": "0x1", "hash": fmt.Sprintf("0x%064x", n), "transactions": txs})
+	return `{"jsonrpc":"2.0","id":0,"result":` + string(result) + `}`
+}
+
+type benchBlock struct {
+	Number       string `json:"number"`
+	Hash         string `json:"hash"`
+	Transactions []struct {
+		Hash     string `json:"hash"`
+		From     string `json:"from"`
+		To       string `json:"to"`
+		Nonce    string `json:"nonce"`
+		Value    string `json:"value"`
+		Gas      uint64 `json:"gas"`
+		GasPrice uint64 `json:"gasPrice"`
+		Input    string `json:"input"`
+	} `json:"transactions"`
+}
+
+func BenchmarkGetObject(b *testing.B) {
+	var wire *wireResponse
+	if err := json.Unmarshal([]byte(largeResultBody(500)), &wire); err != nil {
+		b.Fatal(err)
+	}
+	response, err := wire.toRPCResponse(true)
+	if err != nil {
+		b.Fatal(err)
+	}
+	withoutRaw := *response
+	withoutRaw.RawResult = nil
+
+	b.Run("raw result", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var block benchBlock
+			if err := response.GetObject(&block); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	// re-encodes decoded result as it was done before raw result was kept
+	b.Run("decoded result", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var block benchBlock
+			if err := withoutRaw.GetObject(&block); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkCallLargeResult(b *testing.B) {
+	body := []byte(largeResultBody(500))
+	benchServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		defer r.Body.Close()
+		_, _ = w.Write(body)
+	}))
+	defer benchServer.Close()
+
+	rpcClient := NewClient(benchServer.URL)
+	b.Run("CallFor", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var block benchBlock
+			if err := rpcClient.CallFor(context.Background(), &block, "eth_getBlockByNumber", "0x1", true); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("typed Call", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := Call[benchBlock](context.Background(), rpcClient, "eth_getBlockByNumber", "0x1", true); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package rpcclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// CompressionGzip compresses request bodies with gzip
+	CompressionGzip = "gzip"
+	// CompressionZstd compresses request bodies with zstd
+	CompressionZstd = "zstd"
+
+	// acceptEncoding is sent when compression is enabled
+	acceptEncoding = "zstd, gzip"
+
+	// max memory used by zstd decoder of the response, responses with bigger window are rejected
+	zstdMaxDecoderMemory = 64 << 20
+)
+
+// zstdEncoder is shared by all clients, EncodeAll can be called concurrently
+var zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+	return zstd.NewWriter(nil)
+})
+
+func compressBody(body []byte, compression string) ([]byte, error) {
+	var buf bytes.Buffer
+	switch compression {
+	case CompressionGzip:
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(body); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+	case CompressionZstd:
+		encoder, err := zstdEncoder()
+		if err != nil {
+			return nil, err
+		}
+		return encoder.EncodeAll(body, nil), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", compression)
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressResponseBody returns reader of the response body decompressed according to the Content-Encoding header.
+// Go http.Transport decompresses gzip itself and removes the header if it requested compression.
+func decompressResponseBody(httpResponse *http.Response) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(httpResponse.Header.Get("Content-Encoding"))) {
+	case CompressionGzip:
+		return gzip.NewReader(httpResponse.Body)
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(httpResponse.Body,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxMemory(zstdMaxDecoderMemory),
+		)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return io.NopCloser(httpResponse.Body), nil
+	}
+}
@@ -0,0 +1,426 @@
+package rpcclient
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultFailureThreshold = 3
+	DefaultCircuitCooldown  = 10 * time.Second
+)
+
+var _ RPCClient = (*MultiClient)(nil)
+
+var (
+	ErrNoEndpoints = errors.New("no endpoints")
+	ErrNoQuorum    = errors.New("quorum not reached")
+	// ErrInvalidQuorum is returned by NewMultiClient if quorum is negative or bigger than number of endpoints
+	ErrInvalidQuorum = errors.New("invalid quorum")
+)
+
+type MultiClientMode int
+
+const (
+	// ModeFailover sends request to the first healthy endpoint and tries the next one on connection or HTTP error
+	ModeFailover MultiClientMode = iota
+	// ModeFanOut sends request to all healthy endpoints concurrently and returns the first successful response,
+	// requests to other endpoints continue in the background until they complete or context is cancelled.
+	// Use CallAll and CallBatchAll to get responses of all endpoints.
+	ModeFanOut
+	// ModeQuorum sends request to all healthy endpoints concurrently and returns the response
+	// as soon as Quorum endpoints return the same result
+	ModeQuorum
+)
+
+type MultiClientOpts struct {
+	Mode MultiClientMode
+	// Number of matching responses for ModeQuorum, majority of endpoints if 0. Must not exceed number of endpoints
+	Quorum int
+	// Number of consecutive connection or HTTP errors after which endpoint is skipped, DefaultFailureThreshold if 0
+	FailureThreshold int
+	// How long failed endpoint is skipped before the next attempt, DefaultCircuitCooldown if 0
+	Cooldown time.Duration
+}
+
+// EndpointResult is the result of the call to one endpoint
+type EndpointResult struct {
+	Endpoint string
+	Response *RPCResponse
+	Err      error
+}
+
+// EndpointBatchResult is the result of the batch call to one endpoint
+type EndpointBatchResult struct {
+	Endpoint  string
+	Responses RPCResponses
+	Err       error
+}
+
+// MultiClient implements RPCClient sending requests to multiple endpoints.
+// Endpoints that fail FailureThreshold times in a row are skipped for Cooldown,
+// if all endpoints are skipped requests are sent to all of them.
+type MultiClient struct {
+	opts      MultiClientOpts
+	endpoints []*multiClientEndpoint
+}
+
+type multiClientEndpoint struct {
+	name   string
+	client RPCClient
+
+	mu                  sync.Mutex
+	consecutiveFailures int
+	skipUntil           time.Time
+}
+
+// NewMultiClient creates client for the endpoints, each endpoint uses client created with clientOpts
+func NewMultiClient(endpoints []string, clientOpts *RPCClientOpts, opts MultiClientOpts) (*MultiClient, error) {
+	if len(endpoints) == 0 {
+		return nil, ErrNoEndpoints
+	}
+	if opts.Quorum < 0 || opts.Quorum > len(endpoints) {
+		return nil, fmt.Errorf("%w: %d, number of endpoints %d", ErrInvalidQuorum, opts.Quorum, len(endpoints))
+	}
+	if opts.Quorum == 0 {
+		opts.Quorum = len(endpoints)/2 + 1
+	}
+	if opts.FailureThreshold == 0 {
+		opts.FailureThreshold = DefaultFailureThreshold
+	}
+	if opts.Cooldown == 0 {
+		opts.Cooldown = DefaultCircuitCooldown
+	}
+
+	client := &MultiClient{opts: opts}
+	for _, endpoint := range endpoints {
+		client.endpoints = append(client.endpoints, &multiClientEndpoint{
+			name:   redactEndpoint(endpoint),
+			client: NewClientWithOpts(endpoint, clientOpts),
+		})
+	}
+	return client, nil
+}
+
+func redactEndpoint(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	return u.Redacted()
+}
+
+func (e *multiClientEndpoint) isHealthy(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return now.After(e.skipUntil)
+}
+
+// recordResult updates health of the endpoint, JSON-RPC errors are not failures of the endpoint
+func (e *multiClientEndpoint) recordResult(err error, opts *MultiClientOpts) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err == nil {
+		e.consecutiveFailures = 0
+		return
+	}
+	e.consecutiveFailures++
+	if e.consecutiveFailures >= opts.FailureThreshold {
+		e.skipUntil = time.Now().Add(opts.Cooldown)
+	}
+}
+
+// Healthy returns endpoints that are not skipped by the circuit breaker
+func (m *MultiClient) Healthy() []string {
+	var result []string
+	for _, endpoint := range m.healthyEndpoints() {
+		result = append(result, endpoint.name)
+	}
+	return result
+}
+
+func (m *MultiClient) healthyEndpoints() []*multiClientEndpoint {
+	now := time.Now()
+	var healthy []*multiClientEndpoint
+	for _, endpoint := range m.endpoints {
+		if endpoint.isHealthy(now) {
+			healthy = append(healthy, endpoint)
+		}
+	}
+	if len(healthy) == 0 {
+		return m.endpoints
+	}
+	return healthy
+}
+
+// callResult is the result of the call to one endpoint, response is RPCResponse or RPCResponses
+type callResult[T any] struct {
+	endpoint string
+	response T
+	err      error
+}
+
+func (m *MultiClient) Call(ctx context.Context, method string, params ...any) (*RPCResponse, error) {
+	return m.CallRaw(ctx, m.newCallRequest(method, params...))
+}
+
+func (m *MultiClient) CallRaw(ctx context.Context, request *RPCRequest) (*RPCResponse, error) {
+	return m.callRaw(ctx, request, true)
+}
+
+func (m *MultiClient) newCallRequest(method string, params ...any) *RPCRequest {
+	return NewRequest(method, params...)
+}
+
+func (m *MultiClient) callRaw(ctx context.Context, request *RPCRequest, decodeResult bool) (*RPCResponse, error) {
+	return multiCall(ctx, m, func(ctx context.Context, client RPCClient) (*RPCResponse, error) {
+		return callRaw(ctx, client, request, decodeResult)
+	}, responseQuorumKey)
+}
+
+func (m *MultiClient) CallFor(ctx context.Context, out any, method string, params ...any) error {
+	rpcResponse, err := m.Call(ctx, method, params...)
+	if err != nil {
+		return err
+	}
+	if rpcResponse.Error != nil {
+		return rpcResponse.Error
+	}
+	return rpcResponse.GetObject(out)
+}
+
+func (m *MultiClient) CallBatch(ctx context.Context, requests RPCRequests) (RPCResponses, error) {
+	if err := prepareBatch(requests); err != nil {
+		return nil, err
+	}
+	return m.CallBatchRaw(ctx, requests)
+}
+
+// prepareBatch sets ids and versions of the requests like rpcClient.CallBatch
+func prepareBatch(requests RPCRequests) error {
+	if len(requests) == 0 {
+		return errors.New("empty request list")
+	}
+	for i, req := range requests {
+		req.ID = i
+		req.JSONRPC = jsonrpcVersion
+	}
+	return nil
+}
+
+func (m *MultiClient) CallBatchRaw(ctx context.Context, requests RPCRequests) (RPCResponses, error) {
+	return m.callBatchRaw(ctx, requests, true)
+}
+
+func (m *MultiClient) callBatchRaw(ctx context.Context, requests RPCRequests, decodeResult bool) (RPCResponses, error) {
+	return multiCall(ctx, m, func(ctx context.Context, client RPCClient) (RPCResponses, error) {
+		return callBatchRaw(ctx, client, requests, decodeResult)
+	}, batchQuorumKey)
+}
+
+// CallAll sends request to all healthy endpoints concurrently and returns result of every endpoint
+func (m *MultiClient) CallAll(ctx context.Context, method string, params ...any) []EndpointResult {
+	request := NewRequest(method, params...)
+	results := fanOut(ctx, m.healthyEndpoints(), &m.opts, func(ctx context.Context, client RPCClient) (*RPCResponse, error) {
+		return client.CallRaw(ctx, request)
+	}, nil)
+
+	endpointResults := make([]EndpointResult, 0, len(results))
+	for _, result := range results {
+		endpointResults = append(endpointResults, EndpointResult{
+			Endpoint: result.endpoint,
+			Response: result.response,
+			Err:      result.err,
+		})
+	}
+	return endpointResults
+}
+
+// CallBatchAll sends batch to all healthy endpoints concurrently and returns result of every endpoint,
+// ids of the requests are set like in CallBatch
+func (m *MultiClient) CallBatchAll(ctx context.Context, requests RPCRequests) ([]EndpointBatchResult, error) {
+	if err := prepareBatch(requests); err != nil {
+		return nil, err
+	}
+	results := fanOut(ctx, m.healthyEndpoints(), &m.opts, func(ctx context.Context, client RPCClient) (RPCResponses, error) {
+		return client.CallBatchRaw(ctx, requests)
+	}, nil)
+
+	endpointResults := make([]EndpointBatchResult, 0, len(results))
+	for _, result := range results {
+		endpointResults = append(endpointResults, EndpointBatchResult{
+			Endpoint:  result.endpoint,
+			Responses: result.response,
+			Err:       result.err,
+		})
+	}
+	return endpointResults, nil
+}
+
+func multiCall[T any](ctx context.Context, m *MultiClient, call func(context.Context, RPCClient) (T, error), quorumKey func(T) (string, bool)) (T, error) {
+	endpoints := m.healthyEndpoints()
+	switch m.opts.Mode {
+	case ModeFanOut:
+		return firstSuccess(fanOut(ctx, endpoints, &m.opts, call, func(result callResult[T]) bool {
+			return result.err == nil
+		}))
+	case ModeQuorum:
+		return quorum(ctx, endpoints, &m.opts, call, quorumKey)
+	default:
+		return failover(ctx, endpoints, &m.opts, call)
+	}
+}
+
+func failover[T any](ctx context.Context, endpoints []*multiClientEndpoint, opts *MultiClientOpts, call func(context.Context, RPCClient) (T, error)) (T, error) {
+	var errs []error
+	for _, endpoint := range endpoints {
+		response, err := call(ctx, endpoint.client)
+		endpoint.recordResult(err, opts)
+		if err == nil {
+			return response, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", endpoint.name, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	var zero T
+	return zero, errors.Join(errs...)
+}
+
+// fanOut calls all endpoints concurrently and returns results in endpoints order.
+// If done is set it is called for every result as soon as it's available, fanOut stops waiting when it returns true,
+// calls to the remaining endpoints are not cancelled.
+func fanOut[T any](ctx context.Context, endpoints []*multiClientEndpoint, opts *MultiClientOpts, call func(context.Context, RPCClient) (T, error), done func(callResult[T]) bool) []callResult[T] {
+	resultsCh := make(chan struct {
+		index  int
+		result callResult[T]
+	}, len(endpoints))
+	for i, endpoint := range endpoints {
+		go func() {
+			response, err := call(ctx, endpoint.client)
+			endpoint.recordResult(err, opts)
+			resultsCh <- struct {
+				index  int
+				result callResult[T]
+			}{i, callResult[T]{endpoint: endpoint.name, response: response, err: err}}
+		}()
+	}
+
+	results := make([]callResult[T], len(endpoints))
+	received := make([]bool, len(endpoints))
+	for range endpoints {
+		res := <-resultsCh
+		results[res.index], received[res.index] = res.result, true
+		if done != nil && done(res.result) {
+			break
+		}
+	}
+
+	completed := make([]callResult[T], 0, len(endpoints))
+	for i, result := range results {
+		if received[i] {
+			completed = append(completed, result)
+		}
+	}
+	return completed
+}
+
+func firstSuccess[T any](results []callResult[T]) (T, error) {
+	var errs []error
+	for _, result := range results {
+		if result.err == nil {
+			return result.response, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", result.endpoint, result.err))
+	}
+	var zero T
+	return zero, errors.Join(errs...)
+}
+
+func quorum[T any](ctx context.Context, endpoints []*multiClientEndpoint, opts *MultiClientOpts, call func(context.Context, RPCClient) (T, error), quorumKey func(T) (string, bool)) (T, error) {
+	// remaining calls are not needed after quorum is reached
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		counts   = make(map[string]int)
+		response T
+		reached  bool
+		errs     []error
+	)
+	fanOut(ctx, endpoints, opts, call, func(result callResult[T]) bool {
+		if result.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", result.endpoint, result.err))
+			return false
+		}
+		key, ok := quorumKey(result.response)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %w", result.endpoint, errors.New("response can't be compared")))
+			return false
+		}
+		counts[key]++
+		if counts[key] >= opts.Quorum {
+			response, reached = result.response, true
+		}
+		return reached
+	})
+
+	if !reached {
+		return response, errors.Join(append([]error{ErrNoQuorum}, errs...)...)
+	}
+	return response, nil
+}
+
+// responseQuorumKey returns key that is equal for the responses with the same result or error
+func responseQuorumKey(response *RPCResponse) (string, bool) {
+	if response == nil {
+		return "", false
+	}
+	result := response.Result
+	if result == nil && len(response.RawResult) > 0 {
+		// result is not decoded for the calls of the typed helpers, it's decoded like Result
+		// so the key doesn't depend on the order of the fields and formatting of the numbers
+		if err := decodeWithNumbers(response.RawResult, &result); err != nil {
+			return "", false
+		}
+	}
+	key, err := json.Marshal(struct {
+		Result any       `json:"result"`
+		Error  *RPCError `json:"error"`
+	}{result, response.Error})
+	if err != nil {
+		return "", false
+	}
+	return string(key), true
+}
+
+// batchQuorumKey returns key that is equal for the batches with the same responses in any order
+func batchQuorumKey(responses RPCResponses) (string, bool) {
+	if slices.Contains(responses, nil) {
+		return "", false
+	}
+	responses = slices.SortedFunc(slices.Values(responses), func(a, b *RPCResponse) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	key := ""
+	for _, response := range responses {
+		responseKey, ok := responseQuorumKey(response)
+		if !ok {
+			return "", false
+		}
+		key += fmt.Sprintf("%d:%s;", response.ID, responseKey)
+	}
+	return key, true
+}
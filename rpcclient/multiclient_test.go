@@ -0,0 +1,217 @@
+package rpcclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testEndpoint struct {
+	server *httptest.Server
+	calls  atomic.Int32
+}
+
+// newTestEndpoint returns server that responds with the result, or with HTTP 503 if result is empty
+func newTestEndpoint(t *testing.T, result string) *testEndpoint {
+	t.Helper()
+	endpoint := &testEndpoint{}
+	endpoint.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		endpoint.calls.Add(1)
+		if result == "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		response := `{"jsonrpc":"2.0","id":0,"result":` + result + `}`
+		if len(body) > 0 && body[0] == '[' {
+			response = "[" + response + "]"
+		}
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(endpoint.server.Close)
+	return endpoint
+}
+
+func newTestMultiClient(t *testing.T, opts MultiClientOpts, endpoints ...*testEndpoint) *MultiClient {
+	t.Helper()
+	urls := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		urls = append(urls, endpoint.server.URL)
+	}
+	client, err := NewMultiClient(urls, nil, opts)
+	require.NoError(t, err)
+	return client
+}
+
+func TestMultiClient_Failover(t *testing.T) {
+	failing := newTestEndpoint(t, "")
+	healthy := newTestEndpoint(t, "1")
+	client := newTestMultiClient(t, MultiClientOpts{Mode: ModeFailover, FailureThreshold: 2, Cooldown: time.Hour}, failing, healthy)
+
+	for i := 0; i < 3; i++ {
+		var result int
+		require.NoError(t, client.CallFor(context.Background(), &result, "eth_blockNumber"))
+		require.Equal(t, 1, result)
+	}
+	// failing endpoint is skipped after 2 failures
+	require.Equal(t, int32(2), failing.calls.Load())
+	require.Equal(t, int32(3), healthy.calls.Load())
+	require.Equal(t, []string{healthy.server.URL}, client.Healthy())
+
+	// if all endpoints fail errors of all endpoints are returned
+	client = newTestMultiClient(t, MultiClientOpts{Mode: ModeFailover}, failing, newTestEndpoint(t, ""))
+	_, err := client.Call(context.Background(), "eth_blockNumber")
+	var httpErr *HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	require.Contains(t, err.Error(), failing.server.URL)
+}
+
+func TestMultiClient_FanOut(t *testing.T) {
+	endpoints := []*testEndpoint{newTestEndpoint(t, ""), newTestEndpoint(t, "1"), newTestEndpoint(t, "2")}
+	client := newTestMultiClient(t, MultiClientOpts{Mode: ModeFanOut}, endpoints...)
+
+	res, err := client.Call(context.Background(), "eth_sendBundle")
+	require.NoError(t, err)
+	result, err := res.GetInt()
+	require.NoError(t, err)
+	// the first successful response is returned
+	require.Contains(t, []int64{1, 2}, result)
+	// requests to the other endpoints are completed in the background
+	for _, endpoint := range endpoints {
+		require.Eventually(t, func() bool { return endpoint.calls.Load() == 1 }, time.Second, time.Millisecond)
+	}
+
+	results := client.CallAll(context.Background(), "eth_sendBundle")
+	require.Len(t, results, 3)
+	require.Error(t, results[0].Err)
+	require.Equal(t, endpoints[0].server.URL, results[0].Endpoint)
+	for i, expected := range []int64{1, 2} {
+		require.NoError(t, results[i+1].Err)
+		result, err := results[i+1].Response.GetInt()
+		require.NoError(t, err)
+		require.Equal(t, expected, result)
+	}
+
+	responses, err := client.CallBatch(context.Background(), RPCRequests{NewRequest("eth_blockNumber")})
+	require.NoError(t, err)
+	require.Len(t, responses, 1)
+}
+
+func TestMultiClient_FanOutFirstSuccess(t *testing.T) {
+	release := make(chan struct{})
+	var slowCalls atomic.Int32
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		slowCalls.Add(1)
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":0,"result":1}`))
+	}))
+	t.Cleanup(slow.Close)
+	t.Cleanup(func() { close(release) })
+	fast := newTestEndpoint(t, "2")
+
+	client, err := NewMultiClient([]string{slow.URL, fast.server.URL}, nil, MultiClientOpts{Mode: ModeFanOut})
+	require.NoError(t, err)
+
+	// response of the fast endpoint is returned without waiting for the slow one
+	res, err := client.Call(context.Background(), "eth_sendBundle")
+	require.NoError(t, err)
+	result, err := res.GetInt()
+	require.NoError(t, err)
+	require.Equal(t, int64(2), result)
+
+	// request to the slow endpoint is not cancelled
+	release <- struct{}{}
+	require.Eventually(t, func() bool { return slowCalls.Load() == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestMultiClient_CallBatchAll(t *testing.T) {
+	endpoints := []*testEndpoint{newTestEndpoint(t, ""), newTestEndpoint(t, "1")}
+	client := newTestMultiClient(t, MultiClientOpts{Mode: ModeFanOut}, endpoints...)
+
+	results, err := client.CallBatchAll(context.Background(), RPCRequests{NewRequest("eth_blockNumber")})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.Equal(t, endpoints[0].server.URL, results[0].Endpoint)
+	require.Error(t, results[0].Err)
+	require.NoError(t, results[1].Err)
+	require.Len(t, results[1].Responses, 1)
+	result, err := results[1].Responses[0].GetInt()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), result)
+
+	_, err = client.CallBatchAll(context.Background(), nil)
+	require.Error(t, err)
+}
+
+func TestBatchQuorumKey(t *testing.T) {
+	first, ok := batchQuorumKey(RPCResponses{{ID: 0, Result: "a"}, {ID: 1, Result: "b"}})
+	require.True(t, ok)
+	second, ok := batchQuorumKey(RPCResponses{{ID: 1, Result: "b"}, {ID: 0, Result: "a"}})
+	require.True(t, ok)
+	require.Equal(t, first, second)
+
+	other, ok := batchQuorumKey(RPCResponses{{ID: 1, Result: "a"}, {ID: 0, Result: "b"}})
+	require.True(t, ok)
+	require.NotEqual(t, first, other)
+
+	_, ok = batchQuorumKey(RPCResponses{nil})
+	require.False(t, ok)
+}
+
+func TestMultiClient_Quorum(t *testing.T) {
+	testCases := map[string]struct {
+		results        []string
+		quorum         int
+		expectedResult string
+		expectedErr    error
+	}{
+		"majority": {
+			results:        []string{"1", "2", "2"},
+			expectedResult: "2",
+		},
+		"quorum of two with failure": {
+			results:        []string{"", `{"a":1,"b":2}`, `{"b":2,"a":1}`},
+			quorum:         2,
+			expectedResult: "map[a:1 b:2]",
+		},
+		"no quorum": {
+			results:     []string{"1", "2", ""},
+			expectedErr: ErrNoQuorum,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			endpoints := make([]*testEndpoint, 0, len(testCase.results))
+			for _, result := range testCase.results {
+				endpoints = append(endpoints, newTestEndpoint(t, result))
+			}
+			client := newTestMultiClient(t, MultiClientOpts{Mode: ModeQuorum, Quorum: testCase.quorum}, endpoints...)
+
+			res, err := client.Call(context.Background(), "eth_blockNumber")
+			if testCase.expectedErr != nil {
+				require.ErrorIs(t, err, testCase.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			var result any
+			require.NoError(t, res.GetObject(&result))
+			require.Equal(t, testCase.expectedResult, fmt.Sprint(result))
+		})
+	}
+
+	_, err := NewMultiClient([]string{"http://localhost"}, nil, MultiClientOpts{Mode: ModeQuorum, Quorum: 2})
+	require.ErrorIs(t, err, ErrInvalidQuorum)
+	_, err = NewMultiClient([]string{"http://localhost"}, nil, MultiClientOpts{Mode: ModeQuorum, Quorum: -1})
+	require.ErrorIs(t, err, ErrInvalidQuorum)
+	_, err = NewMultiClient([]string{"http://localhost"}, nil, MultiClientOpts{Mode: ModeQuorum, Quorum: 1})
+	require.NoError(t, err)
+}
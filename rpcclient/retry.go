@@ -0,0 +1,187 @@
+package rpcclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+)
+
+const (
+	DefaultRetryMaxAttempts    = 3
+	DefaultRetryInitialBackoff = 100 * time.Millisecond
+	DefaultRetryMaxBackoff     = 5 * time.Second
+
+	// number of attempts made by the call with retry policy
+	callAttemptsHistogram = `goutils_rpcclient_call_attempts{method="%s"}`
+	batchMethodLabel      = "batch"
+)
+
+var (
+	// DefaultRetryableHTTPCodes are retried if RetryPolicy.RetryableHTTPCodes is nil
+	DefaultRetryableHTTPCodes = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+	// DefaultNonIdempotentMethods are used if RetryPolicy.NonIdempotentMethods is nil
+	DefaultNonIdempotentMethods = []string{
+		"eth_sendBundle",
+		"mev_sendBundle",
+		"eth_sendRawTransaction",
+		"eth_sendPrivateTransaction",
+		"eth_sendPrivateRawTransaction",
+		"eth_cancelBundle",
+		"eth_cancelPrivateTransaction",
+	}
+)
+
+// RetryPolicy configures retries of the failed calls.
+// Connection errors, timeouts, responses with RetryableHTTPCodes (even if body is a valid JSON-RPC response)
+// and JSON-RPC errors with RetryableRPCCodes are retried.
+// Batch is retried only if all of its methods can be retried.
+type RetryPolicy struct {
+	// Max number of attempts including the first one, DefaultRetryMaxAttempts is used if 0
+	MaxAttempts int
+	// Delay before the second attempt, doubled for every next attempt. DefaultRetryInitialBackoff is used if 0
+	InitialBackoff time.Duration
+	// Max delay between attempts, DefaultRetryMaxBackoff is used if 0
+	MaxBackoff time.Duration
+	// Fraction of the delay that is randomized, e.g. 0.2 changes delay by up to 20% in both directions
+	Jitter float64
+	// HTTP status codes that are retried, DefaultRetryableHTTPCodes is used if nil
+	RetryableHTTPCodes []int
+	// JSON-RPC error codes that are retried
+	RetryableRPCCodes []int
+	// Methods that are not retried because retry can execute them twice, DefaultNonIdempotentMethods is used if nil
+	NonIdempotentMethods []string
+	// Non-idempotent methods that are retried anyway, e.g. eth_sendBundle that is deduplicated by the server
+	RetryNonIdempotentMethods []string
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts == 0 {
+		return DefaultRetryMaxAttempts
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) canRetryMethod(method string) bool {
+	nonIdempotentMethods := p.NonIdempotentMethods
+	if nonIdempotentMethods == nil {
+		nonIdempotentMethods = DefaultNonIdempotentMethods
+	}
+	return !slices.Contains(nonIdempotentMethods, method) || slices.Contains(p.RetryNonIdempotentMethods, method)
+}
+
+func (p *RetryPolicy) isRetryableError(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return p.isRetryableHTTPCode(httpErr.Code)
+	}
+	return isNetworkError(err)
+}
+
+func (p *RetryPolicy) isRetryableHTTPCode(code int) bool {
+	retryableCodes := p.RetryableHTTPCodes
+	if retryableCodes == nil {
+		retryableCodes = DefaultRetryableHTTPCodes
+	}
+	return slices.Contains(retryableCodes, code)
+}
+
+// isNetworkError returns true if http.Client failed because of the connection error or timeout,
+// errors that won't go away on retry, e.g. unsupported URL scheme or invalid server certificate, are not retried
+func isNetworkError(err error) bool {
+	// expired context of the call is handled by withRetry, http.Client.Timeout is retried
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return false
+	}
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) {
+		return false
+	}
+	// connection is closed by the server before the response is received
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func (p *RetryPolicy) isRetryableResponse(res *RPCResponse) bool {
+	return res != nil && res.Error != nil && slices.Contains(p.RetryableRPCCodes, res.Error.Code)
+}
+
+// backoff returns delay before the next attempt, attempt starts from 1
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	initialBackoff, maxBackoff := p.InitialBackoff, p.MaxBackoff
+	if initialBackoff == 0 {
+		initialBackoff = DefaultRetryInitialBackoff
+	}
+	if maxBackoff == 0 {
+		maxBackoff = DefaultRetryMaxBackoff
+	}
+
+	backoff := initialBackoff
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, maxBackoff)
+	if p.Jitter > 0 {
+		backoff += time.Duration(float64(backoff) * p.Jitter * (2*rand.Float64() - 1))
+	}
+	return backoff
+}
+
+// withRetry calls call until it succeeds or retry is not possible. Nil policy means that call is made once.
+// Result of the last attempt is returned.
+func withRetry[T any](ctx context.Context, policy *RetryPolicy, methodLabel string, methods []string, call func() (T, error), isRetryableResult func(T) bool) (T, error) {
+	if policy == nil {
+		return call()
+	}
+
+	canRetry := true
+	for _, method := range methods {
+		canRetry = canRetry && policy.canRetryMethod(method)
+	}
+
+	attempt := 1
+	defer func() {
+		metrics.GetOrCreateHistogram(fmt.Sprintf(callAttemptsHistogram, methodLabel)).Update(float64(attempt))
+	}()
+	for {
+		result, err := call()
+		retryable := (err != nil && policy.isRetryableError(err)) || (err == nil && isRetryableResult(result))
+		if !canRetry || !retryable || attempt >= policy.maxAttempts() {
+			return result, err
+		}
+
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result, err
+		case <-timer.C:
+		}
+		attempt++
+	}
+}
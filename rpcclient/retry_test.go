@@ -0,0 +1,255 @@
+package rpcclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/flashbots/go-utils/signature"
+	"github.com/stretchr/testify/require"
+)
+
+// newFailingServer returns server that responds with failures before it responds with success
+func newFailingServer(t *testing.T, failures []func(w http.ResponseWriter), attempts *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if _, err := signature.Verify(r.Header.Get(signature.HTTPHeader), body); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		attempt := int(attempts.Add(1))
+		if attempt <= len(failures) {
+			failures[attempt-1](w)
+			return
+		}
+		if body[0] == '[' {
+			_, _ = w.Write([]byte(`[{"jsonrpc":"2.0","id":0,"result":1},{"jsonrpc":"2.0","id":1,"result":2}]`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":0,"result":1}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRetryPolicy(t *testing.T) {
+	signer, err := signature.NewRandomSigner()
+	require.NoError(t, err)
+
+	unavailable := func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) }
+	badRequest := func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadRequest) }
+	rpcError := func(w http.ResponseWriter) {
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":0,"error":{"code":-32005,"message":"limit exceeded"}}`))
+	}
+	unavailableRPCError := func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		rpcError(w)
+	}
+
+	testCases := map[string]struct {
+		policy           *RetryPolicy
+		failures         []func(w http.ResponseWriter)
+		method           string
+		batch            bool
+		expectedAttempts int32
+		expectedErr      bool
+		expectedRPCError bool
+	}{
+		"no policy": {
+			failures:         []func(w http.ResponseWriter){unavailable},
+			method:           "eth_blockNumber",
+			expectedAttempts: 1,
+			expectedErr:      true,
+		},
+		"retry http error": {
+			policy:           &RetryPolicy{InitialBackoff: time.Millisecond},
+			failures:         []func(w http.ResponseWriter){unavailable, unavailable},
+			method:           "eth_blockNumber",
+			expectedAttempts: 3,
+		},
+		"retry http error with rpc response": {
+			policy:           &RetryPolicy{InitialBackoff: time.Millisecond},
+			failures:         []func(w http.ResponseWriter){unavailableRPCError},
+			method:           "eth_blockNumber",
+			expectedAttempts: 2,
+		},
+		"max attempts": {
+			policy:           &RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+			failures:         []func(w http.ResponseWriter){unavailable, unavailable},
+			method:           "eth_blockNumber",
+			expectedAttempts: 2,
+			expectedErr:      true,
+		},
+		"not retryable http code": {
+			policy:           &RetryPolicy{InitialBackoff: time.Millisecond},
+			failures:         []func(w http.ResponseWriter){badRequest},
+			method:           "eth_blockNumber",
+			expectedAttempts: 1,
+			expectedErr:      true,
+		},
+		"retry rpc error": {
+			policy:           &RetryPolicy{InitialBackoff: time.Millisecond, RetryableRPCCodes: []int{-32005}},
+			failures:         []func(w http.ResponseWriter){rpcError},
+			method:           "eth_blockNumber",
+			expectedAttempts: 2,
+		},
+		"not retryable rpc error": {
+			policy:           &RetryPolicy{InitialBackoff: time.Millisecond},
+			failures:         []func(w http.ResponseWriter){rpcError},
+			method:           "eth_blockNumber",
+			expectedAttempts: 1,
+			expectedRPCError: true,
+		},
+		"non-idempotent method": {
+			policy:           &RetryPolicy{InitialBackoff: time.Millisecond},
+			failures:         []func(w http.ResponseWriter){unavailable},
+			method:           "eth_sendBundle",
+			expectedAttempts: 1,
+			expectedErr:      true,
+		},
+		"non-idempotent method opt-in": {
+			policy:           &RetryPolicy{InitialBackoff: time.Millisecond, RetryNonIdempotentMethods: []string{"eth_sendBundle"}},
+			failures:         []func(w http.ResponseWriter){unavailable},
+			method:           "eth_sendBundle",
+			expectedAttempts: 2,
+		},
+		"batch": {
+			policy:           &RetryPolicy{InitialBackoff: time.Millisecond},
+			failures:         []func(w http.ResponseWriter){unavailable},
+			method:           "eth_blockNumber",
+			batch:            true,
+			expectedAttempts: 2,
+		},
+		"batch with non-idempotent method": {
+			policy:           &RetryPolicy{InitialBackoff: time.Millisecond},
+			failures:         []func(w http.ResponseWriter){unavailable},
+			method:           "eth_sendRawTransaction",
+			batch:            true,
+			expectedAttempts: 1,
+			expectedErr:      true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := newFailingServer(t, testCase.failures, &attempts)
+			client := NewClientWithOpts(server.URL, &RPCClientOpts{
+				Signer:      signer,
+				RetryPolicy: testCase.policy,
+			})
+
+			var (
+				res *RPCResponse
+				err error
+			)
+			if testCase.batch {
+				var responses RPCResponses
+				responses, err = client.CallBatch(context.Background(), RPCRequests{
+					NewRequest("eth_blockNumber"),
+					NewRequest(testCase.method),
+				})
+				if len(responses) > 0 {
+					res = responses[0]
+				}
+			} else {
+				res, err = client.Call(context.Background(), testCase.method)
+			}
+
+			require.Equal(t, testCase.expectedAttempts, attempts.Load())
+			if testCase.expectedErr {
+				var httpErr *HTTPError
+				require.True(t, errors.As(err, &httpErr))
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, testCase.expectedRPCError, res.Error != nil)
+		})
+	}
+}
+
+func TestRetryPolicy_ContextCancelled(t *testing.T) {
+	var attempts atomic.Int32
+	server := newFailingServer(t, []func(w http.ResponseWriter){
+		func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) },
+	}, &attempts)
+	signer, err := signature.NewRandomSigner()
+	require.NoError(t, err)
+	client := NewClientWithOpts(server.URL, &RPCClientOpts{
+		Signer:      signer,
+		RetryPolicy: &RetryPolicy{InitialBackoff: time.Hour},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = client.Call(ctx, "eth_blockNumber")
+	require.Error(t, err)
+	require.Equal(t, int32(1), attempts.Load())
+}
+
+func TestIsNetworkError(t *testing.T) {
+	do := func(client *http.Client, url string) error {
+		request, err := http.NewRequest(http.MethodPost, url, nil)
+		require.NoError(t, err)
+		response, err := client.Do(request)
+		if err == nil {
+			response.Body.Close()
+		}
+		require.Error(t, err)
+		return err
+	}
+
+	// connection refused
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	require.True(t, isNetworkError(do(http.DefaultClient, server.URL)))
+
+	// connection is closed without response
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		conn.Close()
+	}))
+	defer server.Close()
+	require.True(t, isNetworkError(do(http.DefaultClient, server.URL)))
+
+	// timeout
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer slowServer.Close()
+	require.True(t, isNetworkError(do(&http.Client{Timeout: 10 * time.Millisecond}, slowServer.URL)))
+
+	// errors that are not fixed by retry
+	require.False(t, isNetworkError(do(http.DefaultClient, "ftp://localhost")))
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsServer.Close()
+	require.False(t, isNetworkError(do(http.DefaultClient, tlsServer.URL)))
+	require.False(t, isNetworkError(errors.New("not a network error")))
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := &RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	require.Equal(t, 100*time.Millisecond, policy.backoff(1))
+	require.Equal(t, 200*time.Millisecond, policy.backoff(2))
+	require.Equal(t, 800*time.Millisecond, policy.backoff(4))
+	require.Equal(t, time.Second, policy.backoff(10))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		backoff := policy.backoff(1)
+		require.GreaterOrEqual(t, backoff, 50*time.Millisecond)
+		require.LessOrEqual(t, backoff, 150*time.Millisecond)
+	}
+}
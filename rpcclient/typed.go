@@ -0,0 +1,113 @@
+package rpcclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// rawResultClient is implemented by the clients of this package, typed helpers use it to keep only raw results
+// of the responses because they decode results directly from the raw bytes
+type rawResultClient interface {
+	// newCallRequest creates the request like Call does
+	newCallRequest(method string, params ...any) *RPCRequest
+	// callRaw is CallRaw that doesn't decode RPCResponse.Result if decodeResult is false
+	callRaw(ctx context.Context, request *RPCRequest, decodeResult bool) (*RPCResponse, error)
+	// callBatchRaw is CallBatchRaw that doesn't decode RPCResponse.Result if decodeResult is false
+	callBatchRaw(ctx context.Context, requests RPCRequests, decodeResult bool) (RPCResponses, error)
+}
+
+// callRaw calls client.CallRaw, results are not decoded if decodeResult is false and client supports it
+func callRaw(ctx context.Context, client RPCClient, request *RPCRequest, decodeResult bool) (*RPCResponse, error) {
+	if rawClient, ok := client.(rawResultClient); ok {
+		return rawClient.callRaw(ctx, request, decodeResult)
+	}
+	return client.CallRaw(ctx, request)
+}
+
+// callBatchRaw calls client.CallBatchRaw, results are not decoded if decodeResult is false and client supports it
+func callBatchRaw(ctx context.Context, client RPCClient, requests RPCRequests, decodeResult bool) (RPCResponses, error) {
+	if rawClient, ok := client.(rawResultClient); ok {
+		return rawClient.callBatchRaw(ctx, requests, decodeResult)
+	}
+	return client.CallBatchRaw(ctx, requests)
+}
+
+// Call is like RPCClient.CallFor but returns the result as T.
+// Result is decoded directly from the response bytes without intermediate decoding into any,
+// clients of this package don't decode RPCResponse.Result for calls made with typed helpers.
+//
+// e.g. Call[hexutil.Uint64](ctx, client, "eth_blockNumber")
+func Call[T any](ctx context.Context, client RPCClient, method string, params ...any) (T, error) {
+	var (
+		response *RPCResponse
+		err      error
+	)
+	if rawClient, ok := client.(rawResultClient); ok {
+		response, err = rawClient.callRaw(ctx, rawClient.newCallRequest(method, params...), false)
+	} else {
+		response, err = client.Call(ctx, method, params...)
+	}
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return DecodeResult[T](response)
+}
+
+// CallBatch sends requests with RPCClient.CallBatch and returns results as T in the order of the requests.
+// Error is returned if any of the requests fails, JSON-RPC errors are wrapped with the index of the request.
+// Use CallBatchResponses and DecodeResult if requests have results of different types.
+func CallBatch[T any](ctx context.Context, client RPCClient, requests RPCRequests) ([]T, error) {
+	responses, err := CallBatchResponses(ctx, client, requests)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]T, len(requests))
+	for i, response := range responses {
+		results[i], err = DecodeResult[T](response)
+		if err != nil {
+			return nil, fmt.Errorf("request %d: %w", i, err)
+		}
+	}
+	return results, nil
+}
+
+// CallBatchResponses sends requests with RPCClient.CallBatch and returns responses in the order of the requests,
+// results are not decoded and should be decoded with DecodeResult
+func CallBatchResponses(ctx context.Context, client RPCClient, requests RPCRequests) (RPCResponses, error) {
+	if err := prepareBatch(requests); err != nil {
+		return nil, err
+	}
+	responses, err := callBatchRaw(ctx, client, requests, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// CallBatch sets request ids to their positions
+	ordered := make(RPCResponses, len(requests))
+	for _, response := range responses {
+		if response == nil || response.ID < 0 || response.ID >= len(requests) {
+			return nil, errors.New("rpc batch call: unexpected response id")
+		}
+		ordered[response.ID] = response
+	}
+	for i, response := range ordered {
+		if response == nil {
+			return nil, fmt.Errorf("rpc batch call: missing response for request %d", i)
+		}
+	}
+	return ordered, nil
+}
+
+// DecodeResult returns the result of the response as T, or response.Error if it is set.
+// Result is decoded from RawResult if it is set.
+func DecodeResult[T any](response *RPCResponse) (T, error) {
+	var result T
+	if response.Error != nil {
+		return result, response.Error
+	}
+	err := response.GetObject(&result)
+	return result, err
+}
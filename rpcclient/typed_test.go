@@ -0,0 +1,164 @@
+package rpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newStaticServer(t *testing.T, response string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+type typedTestBlock struct {
+	Number uint64 `json:"number"`
+	Hash   string `json:"hash"`
+}
+
+func TestCall(t *testing.T) {
+	ctx := context.Background()
+
+	server := newStaticServer(t, `{"jsonrpc":"2.0","id":0,"result":{"number":18446744073709551615,"hash":"0x01"}}`)
+	block, err := Call[typedTestBlock](ctx, NewClient(server.URL), "eth_getBlockByNumber", "latest", false)
+	require.NoError(t, err)
+	require.Equal(t, typedTestBlock{Number: 18446744073709551615, Hash: "0x01"}, block)
+
+	blockPtr, err := Call[*typedTestBlock](ctx, NewClient(newStaticServer(t, `{"jsonrpc":"2.0","id":0,"result":null}`).URL), "eth_getBlockByNumber", "0x1", false)
+	require.NoError(t, err)
+	require.Nil(t, blockPtr)
+
+	server = newStaticServer(t, `{"jsonrpc":"2.0","id":0,"error":{"code":-32000,"message":"failed"}}`)
+	_, err = Call[string](ctx, NewClient(server.URL), "eth_call")
+	require.Equal(t, &RPCError{Code: -32000, Message: "failed"}, err)
+
+	server = newStaticServer(t, `{"jsonrpc":"2.0","id":0,"result":"0x1"}`)
+	_, err = Call[int](ctx, NewClient(server.URL), "eth_blockNumber")
+	require.Error(t, err)
+}
+
+func TestCall_ResultNotDecoded(t *testing.T) {
+	server := newStaticServer(t, `{"jsonrpc":"2.0","id":0,"result":{"number":1}}`)
+	multiClient, err := NewMultiClient([]string{server.URL}, nil, MultiClientOpts{})
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		client RPCClient
+	}{
+		"client":      {client: NewClient(server.URL)},
+		"multiclient": {client: multiClient},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rawClient, ok := testCase.client.(rawResultClient)
+			require.True(t, ok)
+
+			response, err := rawClient.callRaw(context.Background(), NewRequest("eth_getBlockByNumber"), false)
+			require.NoError(t, err)
+			require.Nil(t, response.Result)
+			require.JSONEq(t, `{"number":1}`, string(response.RawResult))
+
+			response, err = testCase.client.Call(context.Background(), "eth_getBlockByNumber")
+			require.NoError(t, err)
+			require.NotNil(t, response.Result)
+		})
+	}
+}
+
+func TestCallBatch(t *testing.T) {
+	ctx := context.Background()
+	requests := func() RPCRequests {
+		return RPCRequests{NewRequest("eth_blockNumber"), NewRequest("eth_blockNumber")}
+	}
+
+	// responses are returned in the order of the requests
+	server := newStaticServer(t, `[{"jsonrpc":"2.0","id":1,"result":"0x2"},{"jsonrpc":"2.0","id":0,"result":"0x1"}]`)
+	results, err := CallBatch[string](ctx, NewClient(server.URL), requests())
+	require.NoError(t, err)
+	require.Equal(t, []string{"0x1", "0x2"}, results)
+
+	server = newStaticServer(t, `[{"jsonrpc":"2.0","id":0,"result":"0x1"},{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"failed"}}]`)
+	_, err = CallBatch[string](ctx, NewClient(server.URL), requests())
+	var rpcErr *RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, -32000, rpcErr.Code)
+	require.ErrorContains(t, err, "request 1")
+
+	// responses of different types
+	server = newStaticServer(t, `[{"jsonrpc":"2.0","id":0,"result":"0x1"},{"jsonrpc":"2.0","id":1,"result":{"number":1,"hash":"0x01"}}]`)
+	responses, err := CallBatchResponses(ctx, NewClient(server.URL), requests())
+	require.NoError(t, err)
+	number, err := DecodeResult[string](responses[0])
+	require.NoError(t, err)
+	require.Equal(t, "0x1", number)
+	block, err := DecodeResult[typedTestBlock](responses[1])
+	require.NoError(t, err)
+	require.Equal(t, typedTestBlock{Number: 1, Hash: "0x01"}, block)
+
+	server = newStaticServer(t, `[{"jsonrpc":"2.0","id":0,"result":"0x1"}]`)
+	_, err = CallBatch[string](ctx, NewClient(server.URL), requests())
+	require.ErrorContains(t, err, "missing response for request 1")
+}
+
+func TestCall_MultiClientQuorum(t *testing.T) {
+	// results are equal regardless of formatting and order of the fields
+	endpoints := []*testEndpoint{
+		newTestEndpoint(t, `{"number": 1, "hash": "0x01"}`),
+		newTestEndpoint(t, `{"hash":"0x01","number":1}`),
+		newTestEndpoint(t, `{"number":2,"hash":"0x01"}`),
+	}
+	client := newTestMultiClient(t, MultiClientOpts{Mode: ModeQuorum, Quorum: 2}, endpoints...)
+
+	block, err := Call[typedTestBlock](context.Background(), client, "eth_getBlockByNumber")
+	require.NoError(t, err)
+	require.Equal(t, typedTestBlock{Number: 1, Hash: "0x01"}, block)
+}
+
+func TestResponseQuorumKey(t *testing.T) {
+	key := func(rawResult string) string {
+		key, ok := responseQuorumKey(&RPCResponse{RawResult: json.RawMessage(rawResult)})
+		require.True(t, ok)
+		return key
+	}
+	require.Equal(t, key(`{"a":1,"b":2}`), key(`{"b":2,"a":1}`))
+	require.Equal(t, key(`{"a":1,"b":2}`), key(` { "a" : 1 , "b" : 2 } `))
+	require.NotEqual(t, key(`{"a":1,"b":2}`), key(`{"a":1,"b":3}`))
+
+	// raw result has the same key as decoded one
+	decoded, ok := responseQuorumKey(&RPCResponse{Result: map[string]any{"a": json.Number("1")}})
+	require.True(t, ok)
+	require.Equal(t, decoded, key(`{"a":1}`))
+}
+
+func TestCall_WebSocketClient(t *testing.T) {
+	client := newTestWebSocketClient(t, newTestWebSocketServer(t))
+
+	result, err := Call[[]int](context.Background(), client, "test_echo", []int{1, 2})
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2}, result)
+
+	results, err := CallBatch[string](context.Background(), client, RPCRequests{
+		NewRequest("test_echo", "a"),
+		NewRequest("test_echo", "b"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, results)
+}
+
+func TestDecodeResult(t *testing.T) {
+	// responses without raw result are decoded from Result
+	number, err := DecodeResult[int](&RPCResponse{Result: 1})
+	require.NoError(t, err)
+	require.Equal(t, 1, number)
+
+	_, err = DecodeResult[int](&RPCResponse{Error: &RPCError{Code: 1}})
+	require.Equal(t, &RPCError{Code: 1}, err)
+}
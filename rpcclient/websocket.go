@@ -0,0 +1,678 @@
+package rpcclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	DefaultReconnectDelay    = time.Second
+	DefaultMaxReconnectDelay = 30 * time.Second
+	// number of notifications buffered for the subscription, subscription is dropped when consumer is slower than that
+	DefaultSubscriptionBufferSize = 1000
+
+	wsWriteTimeout       = 10 * time.Second
+	wsUnsubscribeTimeout = 5 * time.Second
+
+	subscribeMethodSuffix    = "_subscribe"
+	unsubscribeMethodSuffix  = "_unsubscribe"
+	notificationMethodSuffix = "_subscription"
+)
+
+var _ RPCClient = (*WebSocketClient)(nil)
+
+var (
+	ErrClientClosed   = errors.New("websocket client is closed")
+	ErrConnectionLost = errors.New("websocket connection lost")
+	// ErrUnmatchedErrorResponse is returned to the pending batch calls when server responds with an error without valid id,
+	// e.g. to the batch that is too big
+	ErrUnmatchedErrorResponse = errors.New("error response without request id")
+)
+
+type WebSocketClientOpts struct {
+	// Headers sent with the handshake request
+	Header http.Header
+	// websocket.DefaultDialer is used if nil
+	Dialer *websocket.Dialer
+	// Delay before the first reconnect attempt, doubled after every failed attempt up to MaxReconnectDelay.
+	// DefaultReconnectDelay and DefaultMaxReconnectDelay are used if 0.
+	ReconnectDelay    time.Duration
+	MaxReconnectDelay time.Duration
+	// DefaultSubscriptionBufferSize if 0
+	SubscriptionBufferSize int
+}
+
+// WebSocketClient implements RPCClient over one websocket connection.
+// Concurrent requests are multiplexed by id: ids of the requests are replaced by the client
+// and restored in the responses, so callers may use any ids.
+//
+// When connection is lost pending calls fail with ErrConnectionLost, client reconnects in the background
+// and new calls wait for the connection until their context is done. Active subscriptions are
+// created again on the new connection. Error responses without valid id are responses to the rejected batches,
+// they fail calls of all pending batches with ErrUnmatchedErrorResponse because it's not possible to find
+// the batch the error belongs to. Single calls are not affected, such errors are not expected for them because
+// requests are always valid JSON with integer ids.
+type WebSocketClient struct {
+	endpoint string
+	opts     WebSocketClientOpts
+
+	writeMu sync.Mutex
+
+	mu        sync.Mutex
+	conn      *websocket.Conn
+	connected chan struct{} // closed when conn is set
+	nextID    int
+	pending   map[int]*wsPendingCall
+	// all active subscriptions, server ids are valid only for the current connection
+	subscriptions     map[*wsClientSubscription]struct{}
+	subscriptionsByID map[string]*wsClientSubscription
+	closed            bool
+	done              chan struct{}
+}
+
+type wsPendingCall struct {
+	response     chan wsCallResult
+	decodeResult bool
+	// true if call is a part of the batch
+	batch bool
+	// set for <namespace>_subscribe call, subscription is registered as soon as the response is read
+	// so notifications that follow the response are not lost
+	subscription *wsClientSubscription
+}
+
+type wsCallResult struct {
+	response *RPCResponse
+	err      error
+}
+
+type wsClientSubscription struct {
+	namespace string
+	args      []any
+	// server id, guarded by WebSocketClient.mu
+	id string
+
+	notifications chan json.RawMessage
+	done          chan struct{}
+	closeOnce     sync.Once
+}
+
+func (s *wsClientSubscription) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// NewWebSocketClient connects to the websocket endpoint (ws:// or wss://)
+func NewWebSocketClient(ctx context.Context, endpoint string, opts *WebSocketClientOpts) (*WebSocketClient, error) {
+	client := &WebSocketClient{
+		endpoint:          endpoint,
+		connected:         make(chan struct{}),
+		pending:           make(map[int]*wsPendingCall),
+		subscriptions:     make(map[*wsClientSubscription]struct{}),
+		subscriptionsByID: make(map[string]*wsClientSubscription),
+		done:              make(chan struct{}),
+	}
+	if opts != nil {
+		client.opts = *opts
+	}
+	if client.opts.Dialer == nil {
+		client.opts.Dialer = websocket.DefaultDialer
+	}
+	if client.opts.ReconnectDelay == 0 {
+		client.opts.ReconnectDelay = DefaultReconnectDelay
+	}
+	if client.opts.MaxReconnectDelay == 0 {
+		client.opts.MaxReconnectDelay = DefaultMaxReconnectDelay
+	}
+	if client.opts.SubscriptionBufferSize == 0 {
+		client.opts.SubscriptionBufferSize = DefaultSubscriptionBufferSize
+	}
+
+	conn, err := client.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client.setConn(conn)
+	return client, nil
+}
+
+// Close closes the connection, fails pending calls and closes all subscriptions
+func (client *WebSocketClient) Close() error {
+	client.mu.Lock()
+	if client.closed {
+		client.mu.Unlock()
+		return nil
+	}
+	client.closed = true
+	close(client.done)
+	conn := client.conn
+	subscriptions := client.subscriptions
+	client.subscriptions = make(map[*wsClientSubscription]struct{})
+	client.subscriptionsByID = make(map[string]*wsClientSubscription)
+	client.mu.Unlock()
+
+	for sub := range subscriptions {
+		sub.close()
+	}
+	if conn == nil {
+		return nil
+	}
+	// pending calls are failed by the read loop
+	return conn.Close()
+}
+
+func (client *WebSocketClient) Call(ctx context.Context, method string, params ...any) (*RPCResponse, error) {
+	return client.CallRaw(ctx, client.newCallRequest(method, params...))
+}
+
+func (client *WebSocketClient) CallRaw(ctx context.Context, request *RPCRequest) (*RPCResponse, error) {
+	return client.call(ctx, request, true, nil)
+}
+
+func (client *WebSocketClient) newCallRequest(method string, params ...any) *RPCRequest {
+	return NewRequest(method, params...)
+}
+
+func (client *WebSocketClient) callRaw(ctx context.Context, request *RPCRequest, decodeResult bool) (*RPCResponse, error) {
+	return client.call(ctx, request, decodeResult, nil)
+}
+
+func (client *WebSocketClient) CallFor(ctx context.Context, out any, method string, params ...any) error {
+	rpcResponse, err := client.Call(ctx, method, params...)
+	if err != nil {
+		return err
+	}
+
+	if rpcResponse.Error != nil {
+		return rpcResponse.Error
+	}
+
+	return rpcResponse.GetObject(out)
+}
+
+func (client *WebSocketClient) CallBatch(ctx context.Context, requests RPCRequests) (RPCResponses, error) {
+	if len(requests) == 0 {
+		return nil, errors.New("empty request list")
+	}
+
+	for i, req := range requests {
+		req.ID = i
+		req.JSONRPC = jsonrpcVersion
+	}
+
+	return client.CallBatchRaw(ctx, requests)
+}
+
+func (client *WebSocketClient) CallBatchRaw(ctx context.Context, requests RPCRequests) (RPCResponses, error) {
+	return client.callBatchRaw(ctx, requests, true)
+}
+
+func (client *WebSocketClient) callBatchRaw(ctx context.Context, requests RPCRequests, decodeResult bool) (RPCResponses, error) {
+	if len(requests) == 0 {
+		return nil, errors.New("empty request list")
+	}
+
+	conn, err := client.waitConn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("rpc batch call on %v: %w", client.endpoint, err)
+	}
+
+	wireRequests := make([]*RPCRequest, len(requests))
+	calls := make([]*wsPendingCall, len(requests))
+	ids := client.registerCalls(calls, decodeResult, nil, true)
+	defer client.unregisterCalls(ids)
+	for i, request := range requests {
+		wireRequest := *request
+		wireRequest.ID = ids[i]
+		wireRequests[i] = &wireRequest
+	}
+
+	if err := client.write(conn, wireRequests); err != nil {
+		return nil, fmt.Errorf("rpc batch call on %v: %w", client.endpoint, err)
+	}
+
+	responses := make(RPCResponses, 0, len(requests))
+	for i, call := range calls {
+		result, err := client.wait(ctx, call)
+		if err != nil {
+			return nil, fmt.Errorf("rpc batch call on %v: %w", client.endpoint, err)
+		}
+		result.ID = requests[i].ID
+		responses = append(responses, result)
+	}
+	return responses, nil
+}
+
+// Subscribe creates subscription with <namespace>_subscribe call, args usually start with the subscription name,
+// e.g. Subscribe(ctx, "eth", "newHeads"). Results of the notifications are sent to the returned channel.
+//
+// Subscription is cancelled with <namespace>_unsubscribe call when ctx is done. Channel is closed
+// when ctx is done, client is closed, subscription can't be restored after reconnect or
+// consumer doesn't keep up with SubscriptionBufferSize notifications.
+func (client *WebSocketClient) Subscribe(ctx context.Context, namespace string, args ...any) (<-chan json.RawMessage, error) {
+	sub := &wsClientSubscription{
+		namespace:     namespace,
+		args:          args,
+		notifications: make(chan json.RawMessage, client.opts.SubscriptionBufferSize),
+		done:          make(chan struct{}),
+	}
+	if err := client.subscribe(ctx, sub); err != nil {
+		// subscription may be registered if the response was received after ctx is done
+		client.unsubscribe(sub)
+		return nil, err
+	}
+
+	client.mu.Lock()
+	if client.closed {
+		client.mu.Unlock()
+		sub.close()
+	} else {
+		client.subscriptions[sub] = struct{}{}
+		client.mu.Unlock()
+	}
+
+	out := make(chan json.RawMessage)
+	go func() {
+		defer close(out)
+		defer client.unsubscribe(sub)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sub.done:
+				return
+			case notification := <-sub.notifications:
+				select {
+				case out <- notification:
+				case <-ctx.Done():
+					return
+				case <-sub.done:
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// SubscribeFor is like WebSocketClient.Subscribe but decodes notification results into T.
+// Notifications that can't be decoded into T are skipped.
+func SubscribeFor[T any](ctx context.Context, client *WebSocketClient, namespace string, args ...any) (<-chan T, error) {
+	notifications, err := client.Subscribe(ctx, namespace, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for notification := range notifications {
+			var value T
+			if err := json.Unmarshal(notification, &value); err != nil {
+				continue
+			}
+			select {
+			case out <- value:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// subscribe sends <namespace>_subscribe request, subscription is registered by the read loop
+func (client *WebSocketClient) subscribe(ctx context.Context, sub *wsClientSubscription) error {
+	response, err := client.call(ctx, NewRequest(sub.namespace+subscribeMethodSuffix, sub.args...), true, sub)
+	if err != nil {
+		return err
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	if _, ok := response.Result.(string); !ok {
+		return fmt.Errorf("invalid subscription id %v", response.Result)
+	}
+	return nil
+}
+
+// unsubscribe removes subscription and calls <namespace>_unsubscribe if it is active on the current connection
+func (client *WebSocketClient) unsubscribe(sub *wsClientSubscription) {
+	sub.close()
+
+	client.mu.Lock()
+	delete(client.subscriptions, sub)
+	id := sub.id
+	if id != "" && client.subscriptionsByID[id] == sub {
+		delete(client.subscriptionsByID, id)
+	} else {
+		id = ""
+	}
+	client.mu.Unlock()
+
+	if id == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), wsUnsubscribeTimeout)
+	defer cancel()
+	_, _ = client.Call(ctx, sub.namespace+unsubscribeMethodSuffix, id)
+}
+
+func (client *WebSocketClient) call(ctx context.Context, request *RPCRequest, decodeResult bool, sub *wsClientSubscription) (*RPCResponse, error) {
+	conn, err := client.waitConn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("rpc call %v() on %v: %w", request.Method, client.endpoint, err)
+	}
+
+	calls := make([]*wsPendingCall, 1)
+	ids := client.registerCalls(calls, decodeResult, sub, false)
+	defer client.unregisterCalls(ids)
+
+	wireRequest := *request
+	wireRequest.ID = ids[0]
+	if err := client.write(conn, &wireRequest); err != nil {
+		return nil, fmt.Errorf("rpc call %v() on %v: %w", request.Method, client.endpoint, err)
+	}
+
+	response, err := client.wait(ctx, calls[0])
+	if err != nil {
+		return nil, fmt.Errorf("rpc call %v() on %v: %w", request.Method, client.endpoint, err)
+	}
+	response.ID = request.ID
+	return response, nil
+}
+
+// registerCalls creates pending calls with unique ids
+func (client *WebSocketClient) registerCalls(calls []*wsPendingCall, decodeResult bool, sub *wsClientSubscription, batch bool) []int {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	ids := make([]int, len(calls))
+	for i := range calls {
+		client.nextID++
+		ids[i] = client.nextID
+		calls[i] = &wsPendingCall{response: make(chan wsCallResult, 1), decodeResult: decodeResult, subscription: sub, batch: batch}
+		client.pending[ids[i]] = calls[i]
+	}
+	return ids
+}
+
+func (client *WebSocketClient) unregisterCalls(ids []int) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	for _, id := range ids {
+		delete(client.pending, id)
+	}
+}
+
+func (client *WebSocketClient) wait(ctx context.Context, call *wsPendingCall) (*RPCResponse, error) {
+	select {
+	case result := <-call.response:
+		return result.response, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (client *WebSocketClient) write(conn *websocket.Conn, v any) error {
+	client.writeMu.Lock()
+	defer client.writeMu.Unlock()
+
+	if err := conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+		return err
+	}
+	return conn.WriteJSON(v)
+}
+
+// waitConn returns current connection or waits until client is reconnected
+func (client *WebSocketClient) waitConn(ctx context.Context) (*websocket.Conn, error) {
+	for {
+		client.mu.Lock()
+		if client.closed {
+			client.mu.Unlock()
+			return nil, ErrClientClosed
+		}
+		if client.conn != nil {
+			conn := client.conn
+			client.mu.Unlock()
+			return conn, nil
+		}
+		connected := client.connected
+		client.mu.Unlock()
+
+		select {
+		case <-connected:
+		case <-client.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (client *WebSocketClient) dial(ctx context.Context) (*websocket.Conn, error) {
+	conn, resp, err := client.opts.Dialer.DialContext(ctx, client.endpoint, client.opts.Header)
+	if resp != nil && resp.Body != nil {
+		resp.Body.Close()
+	}
+	if err != nil {
+		if resp != nil {
+			return nil, &HTTPError{
+				Code: resp.StatusCode,
+				err:  fmt.Errorf("websocket dial %v: %w", client.endpoint, err),
+			}
+		}
+		return nil, fmt.Errorf("websocket dial %v: %w", client.endpoint, err)
+	}
+	return conn, nil
+}
+
+func (client *WebSocketClient) setConn(conn *websocket.Conn) {
+	client.mu.Lock()
+	client.conn = conn
+	close(client.connected)
+	client.mu.Unlock()
+
+	go client.readLoop(conn)
+}
+
+func (client *WebSocketClient) readLoop(conn *websocket.Conn) {
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			client.handleDisconnect(conn)
+			return
+		}
+		client.handleMessage(data)
+	}
+}
+
+// handleDisconnect fails pending calls and starts reconnecting unless client is closed
+func (client *WebSocketClient) handleDisconnect(conn *websocket.Conn) {
+	conn.Close()
+
+	client.mu.Lock()
+	if client.conn == conn {
+		client.conn = nil
+		client.connected = make(chan struct{})
+	}
+	for id, call := range client.pending {
+		call.response <- wsCallResult{err: ErrConnectionLost}
+		delete(client.pending, id)
+	}
+	client.subscriptionsByID = make(map[string]*wsClientSubscription)
+	closed := client.closed
+	client.mu.Unlock()
+
+	if !closed {
+		go client.reconnect()
+	}
+}
+
+func (client *WebSocketClient) reconnect() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-client.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	delay := client.opts.ReconnectDelay
+	for {
+		select {
+		case <-time.After(delay):
+		case <-client.done:
+			return
+		}
+
+		conn, err := client.dial(ctx)
+		if err == nil {
+			client.mu.Lock()
+			if client.closed {
+				client.mu.Unlock()
+				conn.Close()
+				return
+			}
+			client.mu.Unlock()
+			client.setConn(conn)
+			break
+		}
+		delay = min(2*delay, client.opts.MaxReconnectDelay)
+	}
+
+	client.mu.Lock()
+	subscriptions := make([]*wsClientSubscription, 0, len(client.subscriptions))
+	for sub := range client.subscriptions {
+		subscriptions = append(subscriptions, sub)
+	}
+	client.mu.Unlock()
+
+	for _, sub := range subscriptions {
+		if err := client.subscribe(ctx, sub); err != nil {
+			sub.close()
+		}
+	}
+}
+
+type wsMessageHeader struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Error  json.RawMessage `json:"error"`
+}
+
+type wsNotificationParams struct {
+	Subscription string          `json:"subscription"`
+	Result       json.RawMessage `json:"result"`
+}
+
+func (client *WebSocketClient) handleMessage(data []byte) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var messages []json.RawMessage
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return
+		}
+		for _, message := range messages {
+			client.handleSingleMessage(message)
+		}
+		return
+	}
+	client.handleSingleMessage(data)
+}
+
+func (client *WebSocketClient) handleSingleMessage(data []byte) {
+	var header wsMessageHeader
+	if err := json.Unmarshal(data, &header); err != nil {
+		return
+	}
+
+	if strings.HasSuffix(header.Method, notificationMethodSuffix) {
+		var params wsNotificationParams
+		if err := json.Unmarshal(header.Params, &params); err != nil {
+			return
+		}
+		client.handleNotification(params)
+		return
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	// ids of the requests are always integers, other ids are null or set by a misbehaving server
+	var id int
+	if bytes.Equal(header.ID, []byte("null")) || json.Unmarshal(header.ID, &id) != nil {
+		var wire *wireError
+		if json.Unmarshal(header.Error, &wire) == nil && wire != nil {
+			client.failPendingBatches(wire)
+		}
+		return
+	}
+
+	call, ok := client.pending[id]
+	if !ok {
+		return
+	}
+	delete(client.pending, id)
+
+	var wire *wireResponse
+	err := json.Unmarshal(data, &wire)
+	var response *RPCResponse
+	if err == nil {
+		response, err = wire.toRPCResponse(call.decodeResult || call.subscription != nil)
+	}
+	if err != nil || response == nil {
+		call.response <- wsCallResult{err: fmt.Errorf("could not decode rpc response: %s", data)}
+		return
+	}
+
+	if call.subscription != nil && response.Error == nil {
+		if id, ok := response.Result.(string); ok {
+			call.subscription.id = id
+			client.subscriptionsByID[id] = call.subscription
+		}
+	}
+	call.response <- wsCallResult{response: response}
+}
+
+// failPendingBatches fails calls of the pending batches with the error response that can't be matched to a request,
+// client.mu must be held
+func (client *WebSocketClient) failPendingBatches(wire *wireError) {
+	rpcErr := wire.toRPCError()
+	for id, call := range client.pending {
+		if !call.batch {
+			continue
+		}
+		call.response <- wsCallResult{err: fmt.Errorf("%w: %w", ErrUnmatchedErrorResponse, rpcErr)}
+		delete(client.pending, id)
+	}
+}
+
+func (client *WebSocketClient) handleNotification(params wsNotificationParams) {
+	client.mu.Lock()
+	sub, ok := client.subscriptionsByID[params.Subscription]
+	client.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	select {
+	case sub.notifications <- params.Result:
+	case <-sub.done:
+	default:
+		// consumer is too slow, drop the subscription instead of blocking all calls of the connection
+		sub.close()
+	}
+}
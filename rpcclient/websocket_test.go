@@ -0,0 +1,337 @@
+package rpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// testWebSocketServer echoes the first param of "test_echo" after the delay in ms from the second param,
+// and supports "test_subscribe" subscriptions that receive values passed to notify.
+// "test_nullID" and "test_stringID" respond with errors without valid id, batches with "test_nullID"
+// are rejected with one error like batches that are too big.
+type testWebSocketServer struct {
+	server *httptest.Server
+
+	mu            sync.Mutex
+	conns         map[*testWebSocketConn]struct{}
+	subscriptions map[string]*testWebSocketConn
+	nextID        int
+	unsubscribed  []string
+}
+
+type testWebSocketConn struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+}
+
+func (c *testWebSocketConn) write(v any) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = c.conn.WriteJSON(v)
+}
+
+func newTestWebSocketServer(t *testing.T) *testWebSocketServer {
+	t.Helper()
+	s := &testWebSocketServer{
+		conns:         make(map[*testWebSocketConn]struct{}),
+		subscriptions: make(map[string]*testWebSocketConn),
+	}
+	upgrader := websocket.Upgrader{}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		c := &testWebSocketConn{conn: conn}
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			delete(s.conns, c)
+			for id, subConn := range s.subscriptions {
+				if subConn == c {
+					delete(s.subscriptions, id)
+				}
+			}
+			s.mu.Unlock()
+			conn.Close()
+		}()
+
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if data[0] == '[' {
+				var requests []*RPCRequest
+				if json.Unmarshal(data, &requests) != nil {
+					return
+				}
+				if slices.ContainsFunc(requests, func(request *RPCRequest) bool { return request.Method == "test_nullID" }) {
+					c.write(map[string]any{"jsonrpc": "2.0", "id": nil, "error": map[string]any{"code": -32600, "message": "batch rejected"}})
+					continue
+				}
+				responses := make([]map[string]any, 0, len(requests))
+				for _, request := range requests {
+					responses = append(responses, s.handle(c, request))
+				}
+				c.write(responses)
+				continue
+			}
+			var request *RPCRequest
+			if json.Unmarshal(data, &request) != nil {
+				return
+			}
+			go func() {
+				c.write(s.handle(c, request))
+			}()
+		}
+	}))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *testWebSocketServer) url() string {
+	return "ws" + strings.TrimPrefix(s.server.URL, "http")
+}
+
+func (s *testWebSocketServer) handle(c *testWebSocketConn, request *RPCRequest) map[string]any {
+	response := map[string]any{"jsonrpc": "2.0", "id": request.ID}
+	params, _ := request.Params.([]any)
+	switch request.Method {
+	case "test_echo":
+		if len(params) > 1 {
+			time.Sleep(time.Duration(params[1].(float64)) * time.Millisecond)
+		}
+		response["result"] = params[0]
+	case "test_subscribe":
+		s.mu.Lock()
+		s.nextID++
+		id := fmt.Sprintf("0x%x", s.nextID)
+		s.subscriptions[id] = c
+		s.mu.Unlock()
+		response["result"] = id
+	case "test_nullID", "test_stringID":
+		response["id"] = nil
+		if request.Method == "test_stringID" {
+			response["id"] = "id"
+		}
+		response["error"] = map[string]any{"code": -32700, "message": "parse error"}
+	case "test_unsubscribe":
+		s.mu.Lock()
+		id := params[0].(string)
+		delete(s.subscriptions, id)
+		s.unsubscribed = append(s.unsubscribed, id)
+		s.mu.Unlock()
+		response["result"] = true
+	default:
+		response["error"] = map[string]any{"code": -32601, "message": "method not found"}
+	}
+	return response
+}
+
+// notify sends value to all subscriptions, returns number of subscriptions
+func (s *testWebSocketServer) notify(value any) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, c := range s.subscriptions {
+		c.write(map[string]any{
+			"jsonrpc": "2.0",
+			"method":  "test_subscription",
+			"params":  map[string]any{"subscription": id, "result": value},
+		})
+	}
+	return len(s.subscriptions)
+}
+
+func (s *testWebSocketServer) numSubscriptions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subscriptions)
+}
+
+func (s *testWebSocketServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.conn.Close()
+	}
+}
+
+func newTestWebSocketClient(t *testing.T, s *testWebSocketServer) *WebSocketClient {
+	t.Helper()
+	client, err := NewWebSocketClient(context.Background(), s.url(), &WebSocketClientOpts{ReconnectDelay: 10 * time.Millisecond})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestWebSocketClient_Call(t *testing.T) {
+	client := newTestWebSocketClient(t, newTestWebSocketServer(t))
+	ctx := context.Background()
+
+	var result string
+	require.NoError(t, client.CallFor(ctx, &result, "test_echo", "hello"))
+	require.Equal(t, "hello", result)
+
+	response, err := client.CallRaw(ctx, NewRequestWithID(42, "test_echo", 1))
+	require.NoError(t, err)
+	require.Equal(t, 42, response.ID)
+	number, err := response.GetInt()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), number)
+
+	err = client.CallFor(ctx, &result, "test_unknown")
+	require.Equal(t, &RPCError{Code: -32601, Message: "method not found"}, err)
+
+	responses, err := client.CallBatch(ctx, RPCRequests{
+		NewRequest("test_echo", "a"),
+		NewRequest("test_unknown"),
+	})
+	require.NoError(t, err)
+	require.Len(t, responses, 2)
+	require.Equal(t, "a", responses.GetByID(0).Result)
+	require.NotNil(t, responses.GetByID(1).Error)
+}
+
+func TestWebSocketClient_ConcurrentCalls(t *testing.T) {
+	client := newTestWebSocketClient(t, newTestWebSocketServer(t))
+
+	// responses of the slower calls come later, every call must get its own response
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var result int
+			require.NoError(t, client.CallFor(context.Background(), &result, "test_echo", i, (20-i)*2))
+			require.Equal(t, i, result)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestWebSocketClient_Subscribe(t *testing.T) {
+	server := newTestWebSocketServer(t)
+	client := newTestWebSocketClient(t, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := SubscribeFor[int](ctx, client, "test", "numbers")
+	require.NoError(t, err)
+	require.Equal(t, 1, server.numSubscriptions())
+
+	for i := 0; i < 3; i++ {
+		server.notify(i)
+		require.Equal(t, i, <-ch)
+	}
+
+	cancel()
+	_, ok := <-ch
+	require.False(t, ok)
+	require.Eventually(t, func() bool { return server.numSubscriptions() == 0 }, time.Second, 10*time.Millisecond)
+	server.mu.Lock()
+	require.Equal(t, []string{"0x1"}, server.unsubscribed)
+	server.mu.Unlock()
+
+	_, err = client.Subscribe(context.Background(), "test_unknown")
+	require.Error(t, err)
+}
+
+func TestWebSocketClient_Reconnect(t *testing.T) {
+	server := newTestWebSocketServer(t)
+	client := newTestWebSocketClient(t, server)
+
+	ch, err := SubscribeFor[string](context.Background(), client, "test", "strings")
+	require.NoError(t, err)
+
+	require.Equal(t, 1, server.numSubscriptions())
+	server.dropConnections()
+
+	// subscription is created again on the new connection
+	require.Eventually(t, func() bool {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		return server.subscriptions["0x2"] != nil
+	}, time.Second, 10*time.Millisecond)
+	server.notify("value")
+	require.Equal(t, "value", <-ch)
+
+	var result string
+	require.NoError(t, client.CallFor(context.Background(), &result, "test_echo", "after reconnect"))
+	require.Equal(t, "after reconnect", result)
+
+	require.NoError(t, client.Close())
+	_, ok := <-ch
+	require.False(t, ok)
+	_, err = client.Call(context.Background(), "test_echo", 1)
+	require.ErrorIs(t, err, ErrClientClosed)
+}
+
+func TestWebSocketClient_ConnectionLost(t *testing.T) {
+	server := newTestWebSocketServer(t)
+	client := newTestWebSocketClient(t, server)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := client.Call(context.Background(), "test_echo", 1, 1000)
+		errCh <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	server.dropConnections()
+	require.ErrorIs(t, <-errCh, ErrConnectionLost)
+}
+
+func TestWebSocketClient_UnmatchedErrorResponse(t *testing.T) {
+	server := newTestWebSocketServer(t)
+	client := newTestWebSocketClient(t, server)
+	ctx := context.Background()
+
+	// rejected batch fails
+	_, err := client.CallBatch(ctx, RPCRequests{NewRequest("test_echo", 1), NewRequest("test_nullID")})
+	require.ErrorIs(t, err, ErrUnmatchedErrorResponse)
+	var rpcErr *RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, -32600, rpcErr.Code)
+
+	// concurrent single calls are not affected by the rejected batch
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := client.Call(ctx, "test_echo", 1, 200)
+		errCh <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	_, err = client.CallBatch(ctx, RPCRequests{NewRequest("test_nullID")})
+	require.ErrorIs(t, err, ErrUnmatchedErrorResponse)
+	require.NoError(t, <-errCh)
+
+	// errors without valid id can't be matched to the single calls, they don't fail other calls
+	for _, method := range []string{"test_nullID", "test_stringID"} {
+		go func() {
+			_, err := client.Call(ctx, "test_echo", 1, 200)
+			errCh <- err
+		}()
+		time.Sleep(50 * time.Millisecond)
+		timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		_, err = client.Call(timeoutCtx, method)
+		cancel()
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.NoError(t, <-errCh)
+	}
+
+	// client keeps working
+	var result int
+	require.NoError(t, client.CallFor(ctx, &result, "test_echo", 1))
+	require.Equal(t, 1, result)
+}
@@ -0,0 +1,211 @@
+package rpcserver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials provided")
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrTokenExpired       = errors.New("token expired")
+	ErrInvalidAPIKey      = errors.New("invalid api key")
+	ErrNoClientCert       = errors.New("no verified client certificate")
+	ErrClientCertRejected = errors.New("client certificate is not allowed")
+	ErrEmptyJWTSecret     = errors.New("JWT secret is empty")
+)
+
+const DefaultAPIKeyHeader = "X-API-Key"
+
+type principalKey struct{}
+
+// Authenticator authenticates the request and returns principal that is stored in the context.
+// Principal can be extracted from the context using GetPrincipal with the type returned by the authenticator.
+type Authenticator interface {
+	Authenticate(r *http.Request, body []byte) (any, error)
+}
+
+// GetPrincipal returns principal of the given type stored by the authenticator
+func GetPrincipal[T any](ctx context.Context) (T, bool) {
+	value, ok := ctx.Value(principalKey{}).(T)
+	return value, ok
+}
+
+// authenticate tries authenticators in order and returns principal of the first one that succeeds
+func authenticate(authenticators []Authenticator, r *http.Request, body []byte) (any, error) {
+	var errs []error
+	for _, authenticator := range authenticators {
+		principal, err := authenticator.Authenticate(r, body)
+		if err == nil {
+			return principal, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// JWTPrincipal is a principal of JWTAuthenticator
+type JWTPrincipal struct {
+	Claims map[string]any
+}
+
+// JWTAuthenticator authenticates requests with HS256 JWT from "Authorization: Bearer <token>" header
+// as done by the Engine API.
+type JWTAuthenticator struct {
+	// Must not be empty, all requests are rejected otherwise
+	Secret []byte
+	// If not 0 token must have iat claim that differs from the current time at most by this value
+	// (Engine API uses 60 seconds)
+	MaxIssuedAtDrift time.Duration
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request, _ []byte) (any, error) {
+	// anyone can sign tokens with the empty secret
+	if len(a.Secret) == 0 {
+		return nil, ErrEmptyJWTSecret
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "HS256" {
+		return nil, fmt.Errorf("%w: unsupported algorithm %s", ErrInvalidToken, header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	mac := hmac.New(sha256.New, a.Secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, fmt.Errorf("%w: invalid signature", ErrInvalidToken)
+	}
+
+	var claims map[string]any
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if err := a.validateClaims(claims, time.Now()); err != nil {
+		return nil, err
+	}
+	return JWTPrincipal{Claims: claims}, nil
+}
+
+func (a *JWTAuthenticator) validateClaims(claims map[string]any, now time.Time) error {
+	if exp, ok := claims["exp"].(float64); ok && now.Unix() >= int64(exp) {
+		return ErrTokenExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Unix() < int64(nbf) {
+		return fmt.Errorf("%w: token is not valid yet", ErrInvalidToken)
+	}
+	if a.MaxIssuedAtDrift != 0 {
+		iat, ok := claims["iat"].(float64)
+		if !ok {
+			return fmt.Errorf("%w: missing iat", ErrInvalidToken)
+		}
+		drift := now.Sub(time.Unix(int64(iat), 0)).Abs()
+		if drift > a.MaxIssuedAtDrift {
+			return fmt.Errorf("%w: stale iat", ErrInvalidToken)
+		}
+	}
+	return nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	return nil
+}
+
+// TLSClientPrincipal is a principal of TLSClientCertAuthenticator
+type TLSClientPrincipal struct {
+	Certificate *x509.Certificate
+	CommonName  string
+}
+
+// TLSClientCertAuthenticator authenticates requests with the client certificate verified by the TLS server
+// (tls.Config.ClientAuth must be tls.VerifyClientCertIfGiven or tls.RequireAndVerifyClientCert)
+type TLSClientCertAuthenticator struct {
+	// If not empty only certificates with these subject common names are allowed
+	AllowedCommonNames []string
+}
+
+func (a *TLSClientCertAuthenticator) Authenticate(r *http.Request, _ []byte) (any, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, ErrNoClientCert
+	}
+
+	cert := r.TLS.VerifiedChains[0][0]
+	if len(a.AllowedCommonNames) > 0 && !slices.Contains(a.AllowedCommonNames, cert.Subject.CommonName) {
+		return nil, ErrClientCertRejected
+	}
+	return TLSClientPrincipal{Certificate: cert, CommonName: cert.Subject.CommonName}, nil
+}
+
+// APIKeyPrincipal is a principal of APIKeyAuthenticator
+type APIKeyPrincipal struct {
+	Name string
+}
+
+// APIKeyAuthenticator authenticates requests with static API keys
+type APIKeyAuthenticator struct {
+	// Header with the API key, DefaultAPIKeyHeader is used if empty
+	Header string
+	// Maps API keys to the names of their owners
+	Keys map[string]string
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request, _ []byte) (any, error) {
+	header := a.Header
+	if header == "" {
+		header = DefaultAPIKeyHeader
+	}
+	key := r.Header.Get(header)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	// compare with all keys in constant time to not leak valid keys
+	var principal *APIKeyPrincipal
+	for validKey, name := range a.Keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(validKey)) == 1 {
+			principal = &APIKeyPrincipal{Name: name}
+		}
+	}
+	if principal == nil {
+		return nil, ErrInvalidAPIKey
+	}
+	return *principal, nil
+}
@@ -0,0 +1,170 @@
+package rpcserver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flashbots/go-utils/rpcclient"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestJWT(t *testing.T, alg string, secret []byte, claims map[string]any) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	secret := []byte("secret")
+	authenticator := &JWTAuthenticator{Secret: secret, MaxIssuedAtDrift: time.Minute}
+	now := time.Now().Unix()
+
+	testCases := map[string]struct {
+		token         string
+		expectedError error
+	}{
+		"valid": {
+			token: createTestJWT(t, "HS256", secret, map[string]any{"iat": now, "id": "client"}),
+		},
+		"no token": {
+			token:         "",
+			expectedError: ErrNoCredentials,
+		},
+		"wrong secret": {
+			token:         createTestJWT(t, "HS256", []byte("other"), map[string]any{"iat": now}),
+			expectedError: ErrInvalidToken,
+		},
+		"unsupported algorithm": {
+			token:         createTestJWT(t, "none", secret, map[string]any{"iat": now}),
+			expectedError: ErrInvalidToken,
+		},
+		"malformed": {
+			token:         "abc",
+			expectedError: ErrInvalidToken,
+		},
+		"stale iat": {
+			token:         createTestJWT(t, "HS256", secret, map[string]any{"iat": now - 120}),
+			expectedError: ErrInvalidToken,
+		},
+		"missing iat": {
+			token:         createTestJWT(t, "HS256", secret, map[string]any{}),
+			expectedError: ErrInvalidToken,
+		},
+		"expired": {
+			token:         createTestJWT(t, "HS256", secret, map[string]any{"iat": now, "exp": now - 1}),
+			expectedError: ErrTokenExpired,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodPost, "/", nil)
+			require.NoError(t, err)
+			if testCase.token != "" {
+				request.Header.Set("Authorization", "Bearer "+testCase.token)
+			}
+
+			principal, err := authenticator.Authenticate(request, nil)
+			if testCase.expectedError != nil {
+				require.ErrorIs(t, err, testCase.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "client", principal.(JWTPrincipal).Claims["id"]) //nolint:forcetypeassert
+		})
+	}
+}
+
+func TestJWTAuthenticator_EmptySecret(t *testing.T) {
+	request, err := http.NewRequest(http.MethodPost, "/", nil)
+	require.NoError(t, err)
+	request.Header.Set("Authorization", "Bearer "+createTestJWT(t, "HS256", nil, map[string]any{"iat": time.Now().Unix()}))
+
+	_, err = (&JWTAuthenticator{}).Authenticate(request, nil)
+	require.ErrorIs(t, err, ErrEmptyJWTSecret)
+}
+
+func TestTLSClientCertAuthenticator(t *testing.T) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "builder"}}
+	request, err := http.NewRequest(http.MethodPost, "/", nil)
+	require.NoError(t, err)
+
+	_, err = (&TLSClientCertAuthenticator{}).Authenticate(request, nil)
+	require.ErrorIs(t, err, ErrNoClientCert)
+
+	request.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	principal, err := (&TLSClientCertAuthenticator{}).Authenticate(request, nil)
+	require.NoError(t, err)
+	require.Equal(t, TLSClientPrincipal{Certificate: cert, CommonName: "builder"}, principal)
+
+	_, err = (&TLSClientCertAuthenticator{AllowedCommonNames: []string{"other"}}).Authenticate(request, nil)
+	require.ErrorIs(t, err, ErrClientCertRejected)
+}
+
+func TestHandler_Authenticators(t *testing.T) {
+	secret := []byte("secret")
+	handler, err := NewJSONRPCHandler(map[string]any{
+		"whoami": func(ctx context.Context) (string, error) {
+			if principal, ok := GetPrincipal[APIKeyPrincipal](ctx); ok {
+				return "api key " + principal.Name, nil
+			}
+			if principal, ok := GetPrincipal[JWTPrincipal](ctx); ok {
+				return "jwt " + principal.Claims["id"].(string), nil //nolint:forcetypeassert
+			}
+			return "", nil
+		},
+	}, JSONRPCHandlerOpts{}, map[string]MethodOpts{
+		"whoami": {
+			Authenticators: []Authenticator{
+				&APIKeyAuthenticator{Keys: map[string]string{"key1": "searcher"}},
+				&JWTAuthenticator{Secret: secret},
+			},
+		},
+	})
+	require.NoError(t, err)
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+
+	call := func(headers map[string]string) (string, error) {
+		client := rpcclient.NewClientWithOpts(httpServer.URL, &rpcclient.RPCClientOpts{CustomHeaders: headers})
+		var result string
+		err := client.CallFor(context.Background(), &result, "whoami")
+		return result, err
+	}
+
+	result, err := call(map[string]string{DefaultAPIKeyHeader: "key1"})
+	require.NoError(t, err)
+	require.Equal(t, "api key searcher", result)
+
+	token := createTestJWT(t, "HS256", secret, map[string]any{"id": "client"})
+	result, err = call(map[string]string{"Authorization": "Bearer " + token})
+	require.NoError(t, err)
+	require.Equal(t, "jwt client", result)
+
+	_, err = call(map[string]string{DefaultAPIKeyHeader: "key2"})
+	var rpcErr *rpcclient.RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, CodeInvalidRequest, rpcErr.Code)
+	require.Contains(t, rpcErr.Message, ErrInvalidAPIKey.Error())
+
+	_, err = call(nil)
+	require.ErrorAs(t, err, &rpcErr)
+	require.Contains(t, rpcErr.Message, ErrNoCredentials.Error())
+}
@@ -0,0 +1,231 @@
+package rpcserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultCaptureHeaders are captured if CaptureOpts.Headers is empty
+var DefaultCaptureHeaders = []string{"X-Flashbots-Signature", "X-Flashbots-Origin", "High_prio"}
+
+// CaptureOpts enables capturing of the incoming HTTP requests and responses
+type CaptureOpts struct {
+	// Records are written to the sink after response is sent
+	Sink CaptureSink
+	// Headers that are captured, DefaultCaptureHeaders is used if empty
+	Headers []string
+}
+
+// CaptureRecord is a captured HTTP request with its response, body can be a single request or a batch.
+// Bodies that are valid JSON are stored in Request and Response, other bodies are stored as strings in RawRequest and RawResponse.
+type CaptureRecord struct {
+	Time       time.Time         `json:"time"`
+	ServerName string            `json:"serverName,omitempty"`
+	RemoteAddr string            `json:"remoteAddr,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	// Signer is set if signature was verified while handling the request
+	Signer      *common.Address `json:"signer,omitempty"`
+	Origin      string          `json:"origin,omitempty"`
+	Request     json.RawMessage `json:"request,omitempty"`
+	RawRequest  string          `json:"rawRequest,omitempty"`
+	StatusCode  int             `json:"statusCode"`
+	Response    json.RawMessage `json:"response,omitempty"`
+	RawResponse string          `json:"rawResponse,omitempty"`
+	LatencyMs   float64         `json:"latencyMs"`
+}
+
+// Body returns captured request body
+func (r *CaptureRecord) Body() []byte {
+	if r.Request != nil {
+		return r.Request
+	}
+	return []byte(r.RawRequest)
+}
+
+// CaptureSink stores captured records, it must be safe for concurrent use
+type CaptureSink interface {
+	WriteRecord(record *CaptureRecord) error
+}
+
+func (h *JSONRPCHandler) captureRecord(r *httpRequest, w *captureResponseWriter, startAt time.Time) {
+	headers := h.Capture.Headers
+	if len(headers) == 0 {
+		headers = DefaultCaptureHeaders
+	}
+
+	record := &CaptureRecord{
+		Time:       startAt,
+		ServerName: h.ServerName,
+		RemoteAddr: r.RemoteAddr,
+		Headers:    make(map[string]string),
+		Origin:     r.Header.Get("x-flashbots-origin"),
+		StatusCode: w.statusCode,
+		LatencyMs:  float64(time.Since(startAt).Microseconds()) / 1000,
+	}
+	for _, header := range headers {
+		if value := r.Header.Get(header); value != "" {
+			record.Headers[header] = value
+		}
+	}
+	if signer, verified := r.verifiedSigner(); verified {
+		record.Signer = &signer
+	}
+	if json.Valid(r.body) {
+		record.Request = r.body
+	} else {
+		record.RawRequest = string(r.body)
+	}
+	if response := bytes.TrimSpace(w.body.Bytes()); json.Valid(response) {
+		record.Response = response
+	} else {
+		record.RawResponse = string(response)
+	}
+
+	if err := h.Capture.Sink.WriteRecord(record); err != nil && h.Log != nil {
+		h.Log.Error("failed to write capture record", slog.Any("error", err), slog.String("serverName", h.ServerName))
+	}
+}
+
+// captureResponseWriter copies response body and status code
+type captureResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func newCaptureResponseWriter(w http.ResponseWriter) *captureResponseWriter {
+	return &captureResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+}
+
+func (w *captureResponseWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *captureResponseWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// FileCaptureSink writes records to the file as newline-delimited JSON.
+// When file reaches max size it is rotated: file is renamed to <path>.1, <path>.1 to <path>.2 and so on.
+type FileCaptureSink struct {
+	path         string
+	maxSizeBytes int64
+	maxBackups   int
+
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	size   int64
+}
+
+// NewFileCaptureSink opens file for appending records.
+// If maxSizeBytes is 0 file is never rotated, maxBackups is the number of rotated files that are kept.
+func NewFileCaptureSink(path string, maxSizeBytes int64, maxBackups int) (*FileCaptureSink, error) {
+	s := &FileCaptureSink{
+		path:         path,
+		maxSizeBytes: maxSizeBytes,
+		maxBackups:   maxBackups,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileCaptureSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file = file
+	s.writer = bufio.NewWriter(file)
+	s.size = info.Size()
+	return nil
+}
+
+func (s *FileCaptureSink) WriteRecord(record *CaptureRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	if s.maxSizeBytes > 0 && s.size > 0 && s.size+int64(len(data)) > s.maxSizeBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.writer.Write(data)
+	s.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return s.writer.Flush()
+}
+
+func (s *FileCaptureSink) rotate() error {
+	if err := s.closeFile(); err != nil {
+		return err
+	}
+
+	if s.maxBackups > 0 {
+		for i := s.maxBackups - 1; i >= 1; i-- {
+			if err := os.Rename(backupPath(s.path, i), backupPath(s.path, i+1)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(s.path, backupPath(s.path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(s.path); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func (s *FileCaptureSink) closeFile() error {
+	if err := s.writer.Flush(); err != nil {
+		return err
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// Close flushes and closes the file
+func (s *FileCaptureSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	return s.closeFile()
+}
+
+func backupPath(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
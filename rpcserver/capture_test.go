@@ -0,0 +1,92 @@
+package rpcserver
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/flashbots/go-utils/signature"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryCaptureSink struct {
+	mu      sync.Mutex
+	records []*CaptureRecord
+}
+
+func (s *memoryCaptureSink) WriteRecord(record *CaptureRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, record)
+	return nil
+}
+
+func TestHandler_Capture(t *testing.T) {
+	sink := &memoryCaptureSink{}
+	handler := testHandler(JSONRPCHandlerOpts{
+		ServerName: "capture",
+		Capture:    &CaptureOpts{Sink: sink},
+	}, map[string]MethodOpts{
+		"function": {VerifyRequestSignatureFromHeader: true},
+	})
+
+	signer, err := signature.NewRandomSigner()
+	require.NoError(t, err)
+	body := `{"jsonrpc":"2.0","id":0,"method":"function","params":[123]}`
+	sig, err := signer.Create([]byte(body))
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Flashbots-Signature", sig)
+	request.Header.Set("X-Flashbots-Origin", "test-origin")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	serveTestRequest(t, handler, `{"jsonrpc":"2.0","id":1,`)
+
+	require.Len(t, sink.records, 2)
+	record := sink.records[0]
+	require.Equal(t, "capture", record.ServerName)
+	require.Equal(t, "test-origin", record.Origin)
+	require.NotNil(t, record.Signer)
+	require.Equal(t, signer.Address(), *record.Signer)
+	require.Equal(t, sig, record.Headers["X-Flashbots-Signature"])
+	require.JSONEq(t, body, string(record.Request))
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":0,"result":{"field":123}}`, string(record.Response))
+	require.Equal(t, 200, record.StatusCode)
+
+	record = sink.records[1]
+	require.Nil(t, record.Signer)
+	require.Nil(t, record.Request)
+	require.Equal(t, `{"jsonrpc":"2.0","id":1,`, record.RawRequest)
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"unexpected end of JSON input"}}`, string(record.Response))
+}
+
+func TestFileCaptureSink_Rotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	sink, err := NewFileCaptureSink(path, 150, 2)
+	require.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, sink.WriteRecord(&CaptureRecord{Request: []byte(`{"jsonrpc":"2.0","id":1,"method":"function"}`)}))
+	}
+	require.NoError(t, sink.Close())
+
+	for _, file := range []string{path, path + ".1", path + ".2"} {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+
+		reader := NewCaptureReader(bytes.NewReader(data))
+		record, err := reader.Next()
+		require.NoError(t, err)
+		require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"method":"function"}`, string(record.Request))
+	}
+	_, err = os.Stat(path + ".3")
+	require.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,64 @@
+package rpcserver
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"slices"
+)
+
+// gethContentTypes are accepted by geth in addition to application/json
+var gethContentTypes = []string{"application/json-rpc", "application/jsonrequest"}
+
+// CompatibilityOpts relaxes validation for clients that don't follow JSON-RPC 2.0 spec exactly
+// and selects error codes. Zero value keeps the legacy behavior.
+type CompatibilityOpts struct {
+	// If true Content-Type with parameters is accepted, e.g. "application/json; charset=utf-8"
+	AllowContentTypeParams bool
+	// If true content types accepted by geth are allowed: application/json-rpc and application/jsonrequest
+	AllowGethContentTypes bool
+	// If true requests without jsonrpc field are handled as JSON-RPC 2.0 requests
+	AllowMissingVersion bool
+	// If true errors use codes from the JSON-RPC 2.0 spec, e.g. CodeInvalidRequest for the wrong version
+	// and wrong id type instead of CodeParseError, CodeInvalidRequest for the request without method
+	// instead of CodeMethodNotFound, and error responses to the requests with invalid signature keep request id.
+	Strict bool
+}
+
+func (c *CompatibilityOpts) isValidContentType(contentType string) bool {
+	mediaType := contentType
+	if c.AllowContentTypeParams {
+		parsed, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return false
+		}
+		mediaType = parsed
+	}
+
+	if mediaType == "application/json" {
+		return true
+	}
+	return c.AllowGethContentTypes && slices.Contains(gethContentTypes, mediaType)
+}
+
+func (c *CompatibilityOpts) isValidVersion(version string) bool {
+	return version == "2.0" || (version == "" && c.AllowMissingVersion)
+}
+
+// invalidRequestCode is used for the requests that are valid JSON but not valid JSON-RPC requests,
+// legacy code is CodeParseError
+func (c *CompatibilityOpts) invalidRequestCode() int {
+	if c.Strict {
+		return CodeInvalidRequest
+	}
+	return CodeParseError
+}
+
+// unmarshalErrorCode returns CodeInvalidRequest in strict mode if request is valid JSON but not an object
+func (c *CompatibilityOpts) unmarshalErrorCode(err error) int {
+	var typeErr *json.UnmarshalTypeError
+	if c.Strict && errors.As(err, &typeErr) {
+		return CodeInvalidRequest
+	}
+	return CodeParseError
+}
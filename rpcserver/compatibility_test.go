@@ -0,0 +1,130 @@
+package rpcserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_Compatibility(t *testing.T) {
+	testCases := map[string]struct {
+		opts               CompatibilityOpts
+		contentType        string
+		requestBody        string
+		expectedStatusCode int
+		expectedResponse   string
+	}{
+		"content type with params is rejected by default": {
+			contentType:        "application/json; charset=utf-8",
+			requestBody:        `{"jsonrpc":"2.0","id":1,"method":"function","params":[1]}`,
+			expectedStatusCode: http.StatusUnsupportedMediaType,
+		},
+		"content type with params": {
+			opts:               CompatibilityOpts{AllowContentTypeParams: true},
+			contentType:        "application/json; charset=utf-8",
+			requestBody:        `{"jsonrpc":"2.0","id":1,"method":"function","params":[1]}`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   `{"jsonrpc":"2.0","id":1,"result":{"field":1}}`,
+		},
+		"invalid content type with params": {
+			opts:               CompatibilityOpts{AllowContentTypeParams: true},
+			contentType:        "text/plain; charset=utf-8",
+			requestBody:        `{"jsonrpc":"2.0","id":1,"method":"function","params":[1]}`,
+			expectedStatusCode: http.StatusUnsupportedMediaType,
+		},
+		"geth content type": {
+			opts:               CompatibilityOpts{AllowGethContentTypes: true, AllowContentTypeParams: true},
+			contentType:        "application/json-rpc; charset=utf-8",
+			requestBody:        `{"jsonrpc":"2.0","id":1,"method":"function","params":[1]}`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   `{"jsonrpc":"2.0","id":1,"result":{"field":1}}`,
+		},
+		"missing version": {
+			opts:               CompatibilityOpts{AllowMissingVersion: true},
+			requestBody:        `{"id":1,"method":"function","params":[1]}`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   `{"jsonrpc":"2.0","id":1,"result":{"field":1}}`,
+		},
+		"missing version legacy": {
+			requestBody:        `{"id":1,"method":"function","params":[1]}`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   `{"jsonrpc":"2.0","id":1,"error":{"code":-32700,"message":"invalid jsonrpc version"}}`,
+		},
+		"wrong version strict": {
+			opts:               CompatibilityOpts{Strict: true},
+			requestBody:        `{"jsonrpc":"1.0","id":1,"method":"function","params":[1]}`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   `{"jsonrpc":"2.0","id":1,"error":{"code":-32600,"message":"invalid jsonrpc version"}}`,
+		},
+		"wrong id type strict": {
+			opts:               CompatibilityOpts{Strict: true},
+			requestBody:        `{"jsonrpc":"2.0","id":[1],"method":"function","params":[1]}`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   `{"jsonrpc":"2.0","id":[1],"error":{"code":-32600,"message":"invalid id type"}}`,
+		},
+		"not an object legacy": {
+			requestBody:        `"request"`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"json: cannot unmarshal string into Go value of type rpcserver.jsonRPCRequest"}}`,
+		},
+		"not an object strict": {
+			opts:               CompatibilityOpts{Strict: true},
+			requestBody:        `"request"`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"json: cannot unmarshal string into Go value of type rpcserver.jsonRPCRequest"}}`,
+		},
+		"invalid json strict": {
+			opts:               CompatibilityOpts{Strict: true},
+			requestBody:        `{"jsonrpc":"2.0",`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"unexpected end of JSON input"}}`,
+		},
+		"missing method strict": {
+			opts:               CompatibilityOpts{Strict: true},
+			requestBody:        `{"jsonrpc":"2.0","id":1}`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   `{"jsonrpc":"2.0","id":1,"error":{"code":-32600,"message":"method is missing"}}`,
+		},
+		"invalid signature strict": {
+			opts:               CompatibilityOpts{Strict: true},
+			requestBody:        `{"jsonrpc":"2.0","id":1,"method":"signed","params":[1]}`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   `{"jsonrpc":"2.0","id":1,"error":{"code":-32600,"message":"no signature provided"}}`,
+		},
+	}
+
+	method := func(ctx context.Context, arg1 int) (dummyStruct, error) {
+		return dummyStruct{arg1}, nil
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			handler, err := NewJSONRPCHandler(map[string]any{
+				"function": method,
+				"signed":   method,
+			}, JSONRPCHandlerOpts{Compatibility: testCase.opts}, map[string]MethodOpts{
+				"signed": {VerifyRequestSignatureFromHeader: true},
+			})
+			require.NoError(t, err)
+
+			contentType := testCase.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			request, err := http.NewRequest(http.MethodPost, "/", strings.NewReader(testCase.requestBody))
+			require.NoError(t, err)
+			request.Header.Set("Content-Type", contentType)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, request)
+			require.Equal(t, testCase.expectedStatusCode, rr.Code)
+			if testCase.expectedResponse != "" {
+				require.JSONEq(t, testCase.expectedResponse, rr.Body.String())
+			}
+		})
+	}
+}
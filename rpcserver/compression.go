@@ -0,0 +1,164 @@
+package rpcserver
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	encodingGzip     = "gzip"
+	encodingZstd     = "zstd"
+	encodingIdentity = "identity"
+)
+
+var (
+	errUnsupportedContentEncoding = "unsupported Content-Encoding, supported: gzip, zstd"
+
+	errUnsupportedEncoding = errors.New(errUnsupportedContentEncoding)
+	errBodyTooBig          = errors.New("request body is too big")
+
+	gzipWriterPool = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+	zstdWriterPool = sync.Pool{New: func() any {
+		encoder, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return encoder
+	}}
+)
+
+// readRequestBody reads and decompresses body according to the Content-Encoding header.
+// maxSize is applied to the decompressed body.
+func readRequestBody(body io.Reader, contentEncoding string, maxSize int64) ([]byte, error) {
+	var reader io.Reader
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "", encodingIdentity:
+		reader = body
+	case encodingGzip:
+		gzipReader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	case encodingZstd:
+		zstdReader, err := zstd.NewReader(body,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxMemory(uint64(max(maxSize, zstd.MinWindowSize))),
+			zstd.WithDecoderMaxWindow(uint64(max(maxSize, zstd.MinWindowSize))),
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer zstdReader.Close()
+		reader = zstdReader
+	default:
+		return nil, errUnsupportedEncoding
+	}
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxSize+1))
+	if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+		return nil, errBodyTooBig
+	}
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, errBodyTooBig
+	}
+	return data, nil
+}
+
+// selectResponseEncoding returns preferred encoding supported by the client, zstd is preferred over gzip
+func selectResponseEncoding(acceptEncoding string) string {
+	var gzipAccepted, zstdAccepted bool
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		encoding, params, _ := strings.Cut(part, ";")
+		// encodings with q=0 are not acceptable
+		if name, value, ok := strings.Cut(params, "="); ok && strings.TrimSpace(name) == "q" {
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q == 0 {
+				continue
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(encoding)) {
+		case encodingGzip:
+			gzipAccepted = true
+		case encodingZstd:
+			zstdAccepted = true
+		}
+	}
+	switch {
+	case zstdAccepted:
+		return encodingZstd
+	case gzipAccepted:
+		return encodingGzip
+	default:
+		return ""
+	}
+}
+
+// compressResponseWriter compresses response body, responses without body are not compressed
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	encoder     io.WriteCloser
+	wroteHeader bool
+}
+
+func newCompressResponseWriter(w http.ResponseWriter, encoding string) *compressResponseWriter {
+	w.Header().Add("Vary", "Accept-Encoding")
+	return &compressResponseWriter{ResponseWriter: w, encoding: encoding}
+}
+
+func (w *compressResponseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if statusCode != http.StatusNoContent && statusCode != http.StatusNotModified {
+		w.Header().Set("Content-Encoding", w.encoding)
+		w.Header().Del("Content-Length")
+		switch w.encoding {
+		case encodingGzip:
+			encoder := gzipWriterPool.Get().(*gzip.Writer)
+			encoder.Reset(w.ResponseWriter)
+			w.encoder = encoder
+		case encodingZstd:
+			encoder := zstdWriterPool.Get().(*zstd.Encoder)
+			encoder.Reset(w.ResponseWriter)
+			w.encoder = encoder
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.encoder == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.encoder.Write(b)
+}
+
+// Close flushes compressed data and returns encoder to the pool
+func (w *compressResponseWriter) Close() error {
+	if w.encoder == nil {
+		return nil
+	}
+	err := w.encoder.Close()
+	switch encoder := w.encoder.(type) {
+	case *gzip.Writer:
+		gzipWriterPool.Put(encoder)
+	case *zstd.Encoder:
+		zstdWriterPool.Put(encoder)
+	}
+	w.encoder = nil
+	return err
+}
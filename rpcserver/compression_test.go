@@ -0,0 +1,193 @@
+package rpcserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flashbots/go-utils/rpcclient"
+	"github.com/flashbots/go-utils/signature"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipBody(t *testing.T, body []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write(body)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func zstdBody(t *testing.T, body []byte) []byte {
+	t.Helper()
+	encoder, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	return encoder.EncodeAll(body, nil)
+}
+
+func TestHandler_CompressedRequest(t *testing.T) {
+	handler := testHandler(JSONRPCHandlerOpts{MaxRequestBodySizeBytes: 1000}, nil)
+	request := []byte(`{"jsonrpc":"2.0","id":1,"method":"function","params":[1]}`)
+	// compresses well but is bigger than max body size after decompression
+	bigRequest := []byte(`{"jsonrpc":"2.0","id":1,"method":"function","params":[1],"padding":"` + strings.Repeat("a", 2000) + `"}`)
+
+	testCases := map[string]struct {
+		contentEncoding    string
+		body               []byte
+		expectedStatusCode int
+		expectedResponse   string
+	}{
+		"gzip": {
+			contentEncoding:    "gzip",
+			body:               gzipBody(t, request),
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   `{"jsonrpc":"2.0","id":1,"result":{"field":1}}`,
+		},
+		"zstd": {
+			contentEncoding:    "zstd",
+			body:               zstdBody(t, request),
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   `{"jsonrpc":"2.0","id":1,"result":{"field":1}}`,
+		},
+		"gzip too big after decompression": {
+			contentEncoding:    "gzip",
+			body:               gzipBody(t, bigRequest),
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"request body is too big, max size: 1000"}}`,
+		},
+		"zstd too big after decompression": {
+			contentEncoding:    "zstd",
+			body:               zstdBody(t, bigRequest),
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"request body is too big, max size: 1000"}}`,
+		},
+		"invalid gzip": {
+			contentEncoding:    "gzip",
+			body:               request,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"failed to read request body: gzip: invalid header"}}`,
+		},
+		"unsupported encoding": {
+			contentEncoding:    "br",
+			body:               request,
+			expectedStatusCode: http.StatusUnsupportedMediaType,
+			expectedResponse:   "unsupported Content-Encoding, supported: gzip, zstd\n",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/", bytes.NewReader(testCase.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Content-Encoding", testCase.contentEncoding)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			require.Equal(t, testCase.expectedStatusCode, rr.Code)
+			if rr.Header().Get("Content-Type") == "application/json" {
+				require.JSONEq(t, testCase.expectedResponse, rr.Body.String())
+			} else {
+				require.Equal(t, testCase.expectedResponse, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_CompressedResponse(t *testing.T) {
+	handler := testHandler(JSONRPCHandlerOpts{CompressResponses: true}, nil)
+	request := `{"jsonrpc":"2.0","id":1,"method":"function","params":[1]}`
+	expectedResponse := `{"jsonrpc":"2.0","id":1,"result":{"field":1}}`
+
+	testCases := map[string]struct {
+		acceptEncoding   string
+		expectedEncoding string
+	}{
+		"no compression":  {acceptEncoding: "", expectedEncoding: ""},
+		"gzip":            {acceptEncoding: "gzip, deflate", expectedEncoding: "gzip"},
+		"zstd preferred":  {acceptEncoding: "gzip, zstd", expectedEncoding: "zstd"},
+		"zstd not wanted": {acceptEncoding: "gzip, zstd;q=0", expectedEncoding: "gzip"},
+		"unsupported":     {acceptEncoding: "br", expectedEncoding: ""},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/", strings.NewReader(request))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept-Encoding", testCase.acceptEncoding)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			require.Equal(t, http.StatusOK, rr.Code)
+			require.Equal(t, testCase.expectedEncoding, rr.Header().Get("Content-Encoding"))
+
+			var body []byte
+			switch testCase.expectedEncoding {
+			case "gzip":
+				reader, err := gzip.NewReader(rr.Body)
+				require.NoError(t, err)
+				body, err = io.ReadAll(reader)
+				require.NoError(t, err)
+			case "zstd":
+				decoder, err := zstd.NewReader(rr.Body)
+				require.NoError(t, err)
+				body, err = io.ReadAll(decoder)
+				require.NoError(t, err)
+			default:
+				body = rr.Body.Bytes()
+			}
+			require.JSONEq(t, expectedResponse, string(body))
+		})
+	}
+
+	// notifications don't have body and are not compressed
+	req, err := http.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","method":"function","params":[1]}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	require.Empty(t, rr.Header().Get("Content-Encoding"))
+	require.Empty(t, rr.Body.Bytes())
+}
+
+func TestJSONRPCServerCompressionWithClient(t *testing.T) {
+	handler := testHandler(JSONRPCHandlerOpts{CompressResponses: true}, map[string]MethodOpts{
+		"function": {VerifyRequestSignatureFromHeader: true},
+	})
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+
+	signer, err := signature.NewRandomSigner()
+	require.NoError(t, err)
+
+	for _, compression := range []string{rpcclient.CompressionGzip, rpcclient.CompressionZstd} {
+		client := rpcclient.NewClientWithOpts(httpServer.URL, &rpcclient.RPCClientOpts{
+			Signer:      signer,
+			Compression: compression,
+		})
+
+		var resp dummyStruct
+		err = client.CallFor(context.Background(), &resp, "function", 123)
+		require.NoError(t, err)
+		require.Equal(t, 123, resp.Field)
+
+		responses, err := client.CallBatch(context.Background(), rpcclient.RPCRequests{
+			rpcclient.NewRequest("function", 1),
+			rpcclient.NewRequest("function", 2),
+		})
+		require.NoError(t, err)
+		require.Len(t, responses, 2)
+		require.Nil(t, responses[1].Error)
+	}
+}
@@ -0,0 +1,193 @@
+package rpcserver
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrDedupUnsupported = errors.New("deduplication requires method with one argument that implements UniqueKey() uuid.UUID")
+	ErrInvalidDedupTTL  = errors.New("deduplication TTL must be positive")
+
+	uniqueKeyerType = reflect.TypeOf((*UniqueKeyer)(nil)).Elem()
+)
+
+const DefaultDedupCacheSize = 10_000
+
+// UniqueKeyer is implemented by rpctypes arguments, e.g. EthSendBundleArgs and EthSendRawTransactionArgs
+type UniqueKeyer interface {
+	UniqueKey() uuid.UUID
+}
+
+// DedupOpts enables deduplication of the method calls by the UniqueKey of the argument, the verified signer
+// and the principal of the request, so requests of different callers are never deduplicated.
+// Duplicates are checked after the interceptors, so they are still applied to the duplicate requests.
+// Successful results are cached and returned for the duplicate requests without calling the method,
+// errors are not cached. Concurrent duplicates that arrive before the first call is finished are not deduplicated.
+type DedupOpts struct {
+	// How long result is cached
+	TTL time.Duration
+	// Max number of cached results, least recently used results are evicted first. DefaultDedupCacheSize is used if 0
+	MaxSize int
+}
+
+type deduplicator struct {
+	argType reflect.Type
+	cache   *lruCache
+}
+
+func newDeduplicator(method methodHandler, opts DedupOpts) (*deduplicator, error) {
+	if opts.TTL <= 0 {
+		return nil, ErrInvalidDedupTTL
+	}
+	// first argument is context
+	if len(method.in) != 2 {
+		return nil, ErrDedupUnsupported
+	}
+	argType := method.in[1]
+	if !argType.Implements(uniqueKeyerType) && !reflect.PointerTo(argType).Implements(uniqueKeyerType) {
+		return nil, ErrDedupUnsupported
+	}
+
+	maxSize := opts.MaxSize
+	if maxSize == 0 {
+		maxSize = DefaultDedupCacheSize
+	}
+	return &deduplicator{
+		argType: argType,
+		cache:   newLRUCache(maxSize, opts.TTL),
+	}, nil
+}
+
+// call wraps the method call with deduplication
+func (d *deduplicator) call(call MethodCall, onDuplicate func(method string)) MethodCall {
+	return func(ctx context.Context, method string, params []json.RawMessage) (any, error) {
+		key, ok := d.key(ctx, params)
+		if !ok {
+			return call(ctx, method, params)
+		}
+		if result, found := d.cache.get(key); found {
+			onDuplicate(method)
+			return result, nil
+		}
+		result, err := call(ctx, method, params)
+		if err == nil {
+			d.cache.add(key, result)
+		}
+		return result, err
+	}
+}
+
+// key returns unique key of the request, false is returned if argument can't be decoded
+func (d *deduplicator) key(ctx context.Context, params []json.RawMessage) (uuid.UUID, bool) {
+	argKey, ok := d.argKey(params)
+	if !ok {
+		return uuid.UUID{}, false
+	}
+	principal, _ := GetPrincipal[any](ctx)
+	principalJSON, err := json.Marshal(principal)
+	if err != nil {
+		return uuid.UUID{}, false
+	}
+	signer := GetSigner(ctx)
+	return uuid.NewSHA1(argKey, append(signer.Bytes(), principalJSON...)), true
+}
+
+// argKey returns unique key of the argument, false is returned if argument can't be decoded
+func (d *deduplicator) argKey(params []json.RawMessage) (key uuid.UUID, ok bool) {
+	// UniqueKey of the rpctypes panics on some invalid arguments, e.g. EthCancelBundleArgs without signing address,
+	// such requests are passed to the method without deduplication
+	defer func() {
+		if rec := recover(); rec != nil {
+			key, ok = uuid.UUID{}, false
+		}
+	}()
+
+	if len(params) != 1 {
+		return uuid.UUID{}, false
+	}
+	arg := reflect.New(d.argType)
+	if err := json.Unmarshal(params[0], arg.Interface()); err != nil {
+		return uuid.UUID{}, false
+	}
+
+	keyer, ok := arg.Interface().(UniqueKeyer)
+	if !ok {
+		// argument type is a pointer or an interface, so it implements UniqueKeyer itself
+		if arg.Elem().IsNil() {
+			return uuid.UUID{}, false
+		}
+		keyer = arg.Elem().Interface().(UniqueKeyer)
+	}
+	return keyer.UniqueKey(), true
+}
+
+// lruCache is a size and TTL bounded cache
+type lruCache struct {
+	maxSize int
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	entries map[uuid.UUID]*list.Element
+	order   *list.List
+}
+
+type lruEntry struct {
+	key       uuid.UUID
+	value     any
+	expiresAt time.Time
+}
+
+func newLRUCache(maxSize int, ttl time.Duration) *lruCache {
+	return &lruCache{
+		maxSize: maxSize,
+		ttl:     ttl,
+		entries: make(map[uuid.UUID]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (c *lruCache) get(key uuid.UUID) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry.value, true
+}
+
+func (c *lruCache) add(key uuid.UUID, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*lruEntry)
+		entry.value, entry.expiresAt = value, expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.maxSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
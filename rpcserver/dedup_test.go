@@ -0,0 +1,163 @@
+package rpcserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/flashbots/go-utils/rpctypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_Dedup(t *testing.T) {
+	var (
+		txCalls     int
+		cancelCalls int
+	)
+	handler, err := NewJSONRPCHandler(map[string]any{
+		"eth_sendRawTransaction": func(ctx context.Context, tx rpctypes.EthSendRawTransactionArgs) (int, error) {
+			txCalls++
+			return txCalls, nil
+		},
+		"eth_cancelBundle": func(ctx context.Context, args *rpctypes.EthCancelBundleArgs) (int, error) {
+			cancelCalls++
+			if args.ReplacementUUID == "fail" {
+				return 0, ErrUnknownParam
+			}
+			return cancelCalls, nil
+		},
+	}, JSONRPCHandlerOpts{ServerName: "dedup-test"}, map[string]MethodOpts{
+		"eth_sendRawTransaction": {Dedup: &DedupOpts{TTL: time.Minute}},
+		"eth_cancelBundle":       {Dedup: &DedupOpts{TTL: time.Minute}},
+	})
+	require.NoError(t, err)
+
+	res := serveTestRequest(t, handler, `{"jsonrpc":"2.0","id":1,"method":"eth_sendRawTransaction","params":["0x01"]}`)
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":1}`, res)
+	res = serveTestRequest(t, handler, `{"jsonrpc":"2.0","id":2,"method":"eth_sendRawTransaction","params":["0x01"]}`)
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":2,"result":1}`, res)
+	res = serveTestRequest(t, handler, `{"jsonrpc":"2.0","id":3,"method":"eth_sendRawTransaction","params":["0x02"]}`)
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":3,"result":2}`, res)
+	require.Equal(t, 2, txCalls)
+
+	// pointer arguments and errors are not cached
+	for i := 0; i < 2; i++ {
+		res = serveTestRequest(t, handler, `{"jsonrpc":"2.0","id":1,"method":"eth_cancelBundle","params":[{"replacementUuid":"fail","signingAddress":"0x0000000000000000000000000000000000000001"}]}`)
+		require.Contains(t, res, `"error"`)
+	}
+	for i := 0; i < 2; i++ {
+		res = serveTestRequest(t, handler, `{"jsonrpc":"2.0","id":1,"method":"eth_cancelBundle","params":[{"replacementUuid":"uuid","signingAddress":"0x0000000000000000000000000000000000000001"}]}`)
+		require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":3}`, res)
+	}
+	require.Equal(t, 3, cancelCalls)
+
+	// UniqueKey panics without signing address, request is not deduplicated
+	res = serveTestRequest(t, handler, `{"jsonrpc":"2.0","id":1,"method":"eth_cancelBundle","params":[{"replacementUuid":"uuid"}]}`)
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":4}`, res)
+
+	var buf bytes.Buffer
+	metrics.WritePrometheus(&buf, false)
+	require.Contains(t, buf.String(), `goutils_rpcserver_duplicate_request_total{method="eth_sendRawTransaction",server_name="dedup-test"} 1`)
+	require.Contains(t, buf.String(), `goutils_rpcserver_duplicate_request_total{method="eth_cancelBundle",server_name="dedup-test"} 1`)
+
+	// method argument must implement UniqueKeyer
+	_, err = NewJSONRPCHandler(map[string]any{
+		"function": func(ctx context.Context, arg int) (int, error) { return arg, nil },
+	}, JSONRPCHandlerOpts{}, map[string]MethodOpts{
+		"function": {Dedup: &DedupOpts{TTL: time.Minute}},
+	})
+	require.ErrorIs(t, err, ErrDedupUnsupported)
+}
+
+func TestHandler_DedupInterceptors(t *testing.T) {
+	var (
+		calls  int
+		reject bool
+	)
+	handler, err := NewJSONRPCHandler(map[string]any{
+		"eth_sendRawTransaction": func(ctx context.Context, tx rpctypes.EthSendRawTransactionArgs) (int, error) {
+			calls++
+			return calls, nil
+		},
+	}, JSONRPCHandlerOpts{
+		Interceptors: []Interceptor{func(ctx context.Context, method string, params []json.RawMessage, next MethodCall) (any, error) {
+			if reject {
+				return nil, &JSONRPCError{Code: CodeInvalidRequest, Message: "rejected"}
+			}
+			return next(ctx, method, params)
+		}},
+	}, map[string]MethodOpts{
+		"eth_sendRawTransaction": {Dedup: &DedupOpts{TTL: time.Minute}},
+	})
+	require.NoError(t, err)
+
+	request := `{"jsonrpc":"2.0","id":1,"method":"eth_sendRawTransaction","params":["0x01"]}`
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":1}`, serveTestRequest(t, handler, request))
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":1}`, serveTestRequest(t, handler, request))
+	// interceptors are applied to the duplicates
+	reject = true
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"error":{"code":-32600,"message":"rejected"}}`, serveTestRequest(t, handler, request))
+	require.Equal(t, 1, calls)
+}
+
+func TestDeduplicator_Key(t *testing.T) {
+	method, err := getMethodTypes(func(ctx context.Context, tx rpctypes.EthSendRawTransactionArgs) error { return nil })
+	require.NoError(t, err)
+	deduplicator, err := newDeduplicator(method, DedupOpts{TTL: time.Minute})
+	require.NoError(t, err)
+
+	params := []json.RawMessage{json.RawMessage(`"0x01"`)}
+	key := func(ctx context.Context) uuid.UUID {
+		key, ok := deduplicator.key(ctx, params)
+		require.True(t, ok)
+		return key
+	}
+
+	ctx := context.Background()
+	signerCtx := context.WithValue(ctx, signerKey{}, common.HexToAddress("0x1"))
+	otherSignerCtx := context.WithValue(ctx, signerKey{}, common.HexToAddress("0x2"))
+	principalCtx := context.WithValue(ctx, principalKey{}, APIKeyPrincipal{Name: "a"})
+	otherPrincipalCtx := context.WithValue(ctx, principalKey{}, APIKeyPrincipal{Name: "b"})
+
+	// requests of different callers are not deduplicated
+	keys := []uuid.UUID{key(ctx), key(signerCtx), key(otherSignerCtx), key(principalCtx), key(otherPrincipalCtx)}
+	for i := range keys {
+		for j := range keys {
+			require.Equal(t, i == j, keys[i] == keys[j])
+		}
+	}
+	require.Equal(t, key(signerCtx), key(context.WithValue(ctx, signerKey{}, common.HexToAddress("0x1"))))
+	require.Equal(t, key(principalCtx), key(context.WithValue(ctx, principalKey{}, APIKeyPrincipal{Name: "a"})))
+
+	_, err = newDeduplicator(method, DedupOpts{})
+	require.ErrorIs(t, err, ErrInvalidDedupTTL)
+	_, err = newDeduplicator(method, DedupOpts{TTL: -time.Second})
+	require.ErrorIs(t, err, ErrInvalidDedupTTL)
+}
+
+func TestLRUCache(t *testing.T) {
+	cache := newLRUCache(2, 50*time.Millisecond)
+	keys := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+
+	cache.add(keys[0], 0)
+	cache.add(keys[1], 1)
+	// keys[1] is the least recently used after get
+	_, ok := cache.get(keys[0])
+	require.True(t, ok)
+	cache.add(keys[2], 2)
+
+	_, ok = cache.get(keys[1])
+	require.False(t, ok)
+	value, ok := cache.get(keys[0])
+	require.True(t, ok)
+	require.Equal(t, 0, value)
+
+	time.Sleep(60 * time.Millisecond)
+	_, ok = cache.get(keys[2])
+	require.False(t, ok)
+}
@@ -0,0 +1,64 @@
+package rpcserver
+
+import (
+	"errors"
+
+	"github.com/flashbots/go-utils/jsonrpc"
+)
+
+// Error is implemented by errors that carry JSON-RPC error code.
+// Handler errors implementing it are returned with the given code instead of CodeCustomError.
+type Error = jsonrpc.Error
+
+// DataError is implemented by errors that carry JSON-RPC error data.
+// Handler errors implementing it are returned with the given value in the data field.
+type DataError = jsonrpc.DataError
+
+// JSONRPCError can be returned by handlers to respond with custom error code and data
+type JSONRPCError = jsonrpc.JSONRPCError
+
+// InvalidParamsError is returned with CodeInvalidParams.
+// It is used for params that can't be unmarshalled and can be returned by handlers for params that fail validation.
+type InvalidParamsError struct {
+	Err error
+}
+
+// NewInvalidParamsError wraps err so that it is returned with CodeInvalidParams
+func NewInvalidParamsError(err error) error {
+	return &InvalidParamsError{Err: err}
+}
+
+func (e *InvalidParamsError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InvalidParamsError) Unwrap() error {
+	return e.Err
+}
+
+func (e *InvalidParamsError) ErrorCode() int {
+	return CodeInvalidParams
+}
+
+// errorToJSONRPCError converts handler error to the JSON-RPC error object
+// errors that don't implement Error are returned with CodeCustomError
+func errorToJSONRPCError(err error) *jsonRPCError {
+	res := &jsonRPCError{
+		Code:    CodeCustomError,
+		Message: err.Error(),
+		Data:    nil,
+	}
+
+	var codeErr Error
+	if errors.As(err, &codeErr) {
+		res.Code = codeErr.ErrorCode()
+	}
+
+	var dataErr DataError
+	if errors.As(err, &dataErr) {
+		if data := dataErr.ErrorData(); data != nil {
+			res.Data = &data
+		}
+	}
+	return res
+}
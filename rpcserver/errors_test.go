@@ -0,0 +1,89 @@
+package rpcserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flashbots/go-utils/jsonrpc"
+	"github.com/flashbots/go-utils/rpcclient"
+	"github.com/stretchr/testify/require"
+)
+
+var errBundleTooOld = errors.New("bundle too old")
+
+func TestHandler_ServeHTTPTypedErrors(t *testing.T) {
+	handler, err := NewJSONRPCHandler(map[string]any{
+		"custom": func(ctx context.Context) error {
+			return &JSONRPCError{Code: -32001, Message: "bundle too old", Data: map[string]int{"block": 10}}
+		},
+		"wrapped": func(ctx context.Context) error {
+			return fmt.Errorf("send bundle: %w", &JSONRPCError{Code: -32001, Message: "bundle too old"})
+		},
+		"invalidParams": func(ctx context.Context) error {
+			return NewInvalidParamsError(errBundleTooOld)
+		},
+		"jsonrpcPackageError": func(ctx context.Context) error {
+			return &jsonrpc.JSONRPCError{Code: -32002, Message: "internal failure", Data: "details"}
+		},
+		"plain": func(ctx context.Context) error {
+			return errBundleTooOld
+		},
+	}, JSONRPCHandlerOpts{}, nil)
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		method           string
+		expectedResponse string
+	}{
+		"custom code and data": {
+			method:           "custom",
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"error":{"code":-32001,"message":"bundle too old","data":{"block":10}}}`,
+		},
+		"wrapped error": {
+			method:           "wrapped",
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"error":{"code":-32001,"message":"send bundle: bundle too old"}}`,
+		},
+		"invalid params": {
+			method:           "invalidParams",
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"bundle too old"}}`,
+		},
+		"jsonrpc package error": {
+			method:           "jsonrpcPackageError",
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"error":{"code":-32002,"message":"internal failure","data":"details"}}`,
+		},
+		"plain error": {
+			method:           "plain",
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"bundle too old"}}`,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			body := bytes.NewReader([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"%s"}`, testCase.method)))
+			request, err := http.NewRequest(http.MethodPost, "/", body)
+			require.NoError(t, err)
+			request.Header.Add("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, request)
+			require.Equal(t, http.StatusOK, rr.Code)
+
+			require.JSONEq(t, testCase.expectedResponse, rr.Body.String())
+		})
+	}
+
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+
+	client := rpcclient.NewClient(httpServer.URL)
+	err = client.CallFor(context.Background(), nil, "custom")
+	var rpcErr *rpcclient.RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, -32001, rpcErr.Code)
+}
@@ -0,0 +1,85 @@
+package rpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// HeaderOpts describes HTTP header that is validated and stored in the context passed to the method
+type HeaderOpts struct {
+	// Name of the HTTP header, e.g. x-flashbots-region
+	Name string
+	// Key that is used to get the value with GetHeaderValue, Name is used if empty. Keys are case-insensitive.
+	ContextKey string
+	// If true requests without the header are rejected, otherwise value is not stored in the context
+	Required bool
+	// Max length of the header value, unlimited if 0
+	MaxLength int
+	// If set header value must match the pattern
+	Pattern *regexp.Regexp
+	// If set value returned by Parse is stored in the context instead of the header string
+	Parse func(value string) (any, error)
+}
+
+type headerKey struct {
+	key string
+}
+
+func (o *HeaderOpts) contextKey() headerKey {
+	key := o.ContextKey
+	if key == "" {
+		key = o.Name
+	}
+	return headerKey{key: strings.ToLower(key)}
+}
+
+func validateHeaderOpts(headers []HeaderOpts) error {
+	for _, header := range headers {
+		if header.Name == "" {
+			return errors.New("header name is empty")
+		}
+	}
+	return nil
+}
+
+// extractHeaders validates headers and stores their values in the context,
+// returned error is sent to the user
+func extractHeaders(ctx context.Context, headers []HeaderOpts, header http.Header) (context.Context, error) {
+	for _, opts := range headers {
+		value := header.Get(opts.Name)
+		if value == "" {
+			if opts.Required {
+				return ctx, fmt.Errorf("%s header is required", opts.Name)
+			}
+			continue
+		}
+		if opts.MaxLength > 0 && len(value) > opts.MaxLength {
+			return ctx, fmt.Errorf("%s header is too long", opts.Name)
+		}
+		if opts.Pattern != nil && !opts.Pattern.MatchString(value) {
+			return ctx, fmt.Errorf("%s header has invalid format", opts.Name)
+		}
+
+		var stored any = value
+		if opts.Parse != nil {
+			parsed, err := opts.Parse(value)
+			if err != nil {
+				return ctx, fmt.Errorf("%s header is invalid: %w", opts.Name, err)
+			}
+			stored = parsed
+		}
+		ctx = context.WithValue(ctx, opts.contextKey(), stored)
+	}
+	return ctx, nil
+}
+
+// GetHeaderValue returns value of the header configured with MethodOpts.Headers.
+// T is string unless HeaderOpts.Parse is set, false is returned if header is missing or has another type.
+func GetHeaderValue[T any](ctx context.Context, key string) (T, bool) {
+	value, ok := ctx.Value(headerKey{key: strings.ToLower(key)}).(T)
+	return value, ok
+}
@@ -0,0 +1,109 @@
+package rpcserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_Headers(t *testing.T) {
+	type result struct {
+		Region   string `json:"region"`
+		Priority int    `json:"priority"`
+	}
+	handler, err := NewJSONRPCHandler(map[string]any{
+		"function": func(ctx context.Context) (result, error) {
+			region, _ := GetHeaderValue[string](ctx, "x-flashbots-region")
+			priority, _ := GetHeaderValue[int](ctx, "priority")
+			return result{Region: region, Priority: priority}, nil
+		},
+	}, JSONRPCHandlerOpts{}, map[string]MethodOpts{
+		"function": {
+			Headers: []HeaderOpts{
+				{
+					Name:      "X-Flashbots-Region",
+					Required:  true,
+					MaxLength: 10,
+					Pattern:   regexp.MustCompile(`^[a-z]+-[a-z]+$`),
+				},
+				{
+					Name:       "X-Priority",
+					ContextKey: "Priority",
+					Parse: func(value string) (any, error) {
+						return strconv.Atoi(value)
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		headers          map[string]string
+		expectedResponse string
+	}{
+		"all headers": {
+			headers:          map[string]string{"x-flashbots-region": "eu-west", "x-priority": "5"},
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"result":{"region":"eu-west","priority":5}}`,
+		},
+		"optional header is missing": {
+			headers:          map[string]string{"x-flashbots-region": "eu-west"},
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"result":{"region":"eu-west","priority":0}}`,
+		},
+		"required header is missing": {
+			headers:          map[string]string{"x-priority": "5"},
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"error":{"code":-32600,"message":"X-Flashbots-Region header is required"}}`,
+		},
+		"too long": {
+			headers:          map[string]string{"x-flashbots-region": "europe-west"},
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"error":{"code":-32600,"message":"X-Flashbots-Region header is too long"}}`,
+		},
+		"invalid format": {
+			headers:          map[string]string{"x-flashbots-region": "eu_west"},
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"error":{"code":-32600,"message":"X-Flashbots-Region header has invalid format"}}`,
+		},
+		"parse error": {
+			headers:          map[string]string{"x-flashbots-region": "eu-west", "x-priority": "high"},
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"error":{"code":-32600,"message":"X-Priority header is invalid: strconv.Atoi: parsing \"high\": invalid syntax"}}`,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"function"}`))
+			require.NoError(t, err)
+			request.Header.Set("Content-Type", "application/json")
+			for header, value := range testCase.headers {
+				request.Header.Set(header, value)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, request)
+			require.Equal(t, http.StatusOK, rr.Code)
+			require.JSONEq(t, testCase.expectedResponse, rr.Body.String())
+		})
+	}
+}
+
+func TestGetHeaderValue(t *testing.T) {
+	ctx, err := extractHeaders(context.Background(), []HeaderOpts{{Name: "X-Flashbots-Region"}}, http.Header{
+		"X-Flashbots-Region": []string{"eu-west"},
+	})
+	require.NoError(t, err)
+
+	value, ok := GetHeaderValue[string](ctx, "X-FLASHBOTS-REGION")
+	require.True(t, ok)
+	require.Equal(t, "eu-west", value)
+
+	_, ok = GetHeaderValue[int](ctx, "x-flashbots-region")
+	require.False(t, ok)
+
+	_, ok = GetHeaderValue[string](ctx, "x-flashbots-origin")
+	require.False(t, ok)
+}
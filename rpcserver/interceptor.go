@@ -0,0 +1,25 @@
+package rpcserver
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// MethodCall invokes JSON-RPC method with the positional params
+type MethodCall func(ctx context.Context, method string, params []json.RawMessage) (any, error)
+
+// Interceptor is called around method invocation after request is parsed and headers are extracted into the context.
+// It can modify context or params, short-circuit the call by returning an error or result without calling next,
+// or observe result of the call (e.g. for auth, rate limiting, audit logs and tracing).
+type Interceptor func(ctx context.Context, method string, params []json.RawMessage, next MethodCall) (any, error)
+
+// chainInterceptors wraps call with interceptors, the first interceptor is the outermost one
+func chainInterceptors(interceptors []Interceptor, call MethodCall) MethodCall {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], call
+		call = func(ctx context.Context, method string, params []json.RawMessage) (any, error) {
+			return interceptor(ctx, method, params, next)
+		}
+	}
+	return call
+}
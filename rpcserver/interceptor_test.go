@@ -0,0 +1,58 @@
+package rpcserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flashbots/go-utils/rpcclient"
+	"github.com/stretchr/testify/require"
+)
+
+var errUnauthorized = errors.New("unauthorized")
+
+func TestInterceptors(t *testing.T) {
+	var calls []string
+	recordingInterceptor := func(name string) Interceptor {
+		return func(ctx context.Context, method string, params []json.RawMessage, next MethodCall) (any, error) {
+			calls = append(calls, name+":"+method)
+			return next(ctx, method, params)
+		}
+	}
+	rejectingInterceptor := func(ctx context.Context, method string, params []json.RawMessage, next MethodCall) (any, error) {
+		var arg int
+		if err := json.Unmarshal(params[0], &arg); err != nil {
+			return nil, err
+		}
+		if arg == 0 {
+			return nil, &JSONRPCError{Code: -32001, Message: errUnauthorized.Error()}
+		}
+		return next(ctx, method, params)
+	}
+
+	handler := testHandler(JSONRPCHandlerOpts{
+		Interceptors: []Interceptor{recordingInterceptor("handler1"), recordingInterceptor("handler2")},
+	}, map[string]MethodOpts{
+		"function": {
+			Interceptors: []Interceptor{recordingInterceptor("method"), rejectingInterceptor},
+		},
+	})
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+
+	client := rpcclient.NewClient(httpServer.URL)
+
+	var resp dummyStruct
+	err := client.CallFor(context.Background(), &resp, "function", 123)
+	require.NoError(t, err)
+	require.Equal(t, 123, resp.Field)
+	require.Equal(t, []string{"handler1:function", "handler2:function", "method:function"}, calls)
+
+	err = client.CallFor(context.Background(), &resp, "function", 0)
+	var rpcErr *rpcclient.RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, -32001, rpcErr.Code)
+	require.Equal(t, errUnauthorized.Error(), rpcErr.Message)
+}
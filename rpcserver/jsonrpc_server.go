@@ -9,15 +9,20 @@ package rpcserver
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log/slog"
+	"maps"
 	"net/http"
+	"runtime/debug"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/flashbots/go-utils/signature"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
@@ -25,6 +30,7 @@ var (
 	errMethodNotAllowed = "only POST method is allowed"
 	errWrongContentType = "header Content-Type must be application/json"
 	errMarshalResponse  = "failed to marshal response"
+	errInternalError    = "internal error"
 
 	CodeParseError     = -32700
 	CodeInvalidRequest = -32600
@@ -32,8 +38,10 @@ var (
 	CodeInvalidParams  = -32602
 	CodeInternalError  = -32603
 	CodeCustomError    = -32000
+	CodeLimitExceeded  = -32005
 
 	DefaultMaxRequestBodySizeBytes = 30 * 1024 * 1024 // 30mb
+	DefaultMaxBatchSize            = 100
 )
 
 const (
@@ -44,13 +52,50 @@ type (
 	highPriorityKey struct{}
 	signerKey       struct{}
 	originKey       struct{}
+	requestPathKey  struct{}
 )
 
+// anyPath is the key of the methods that are available on any URL path
+const anyPath = ""
+
 type jsonRPCRequest struct {
-	JSONRPC string            `json:"jsonrpc"`
-	ID      any               `json:"id"`
-	Method  string            `json:"method"`
-	Params  []json.RawMessage `json:"params"`
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+// isNotification returns true for requests without id member, responses to such requests are omitted in batches
+func (r *jsonRPCRequest) isNotification() bool {
+	return r.ID == nil
+}
+
+// hasValidID returns true if id is absent, null, string or number
+func (r *jsonRPCRequest) hasValidID() bool {
+	if len(r.ID) == 0 {
+		return true
+	}
+	switch c := r.ID[0]; {
+	case c == 'n' || c == '"' || c == '-':
+		return true
+	case c >= '0' && c <= '9':
+		return true
+	default:
+		return false
+	}
+}
+
+// isBatch returns true if request body is a JSON array
+func isBatch(body []byte) bool {
+	for _, c := range body {
+		switch c {
+		case ' ', '\t', '\n', '\r':
+			continue
+		default:
+			return c == '['
+		}
+	}
+	return false
 }
 
 type jsonRPCResponse struct {
@@ -58,6 +103,9 @@ type jsonRPCResponse struct {
 	ID      any              `json:"id"`
 	Result  *json.RawMessage `json:"result,omitempty"`
 	Error   *jsonRPCError    `json:"error,omitempty"`
+	// set for the deprecated methods if MethodOpts.DeprecationWarning is set, it is sent in the Warning header
+	// because JSON-RPC response object can't have additional fields
+	warning string
 }
 
 type jsonRPCError struct {
@@ -69,6 +117,14 @@ type jsonRPCError struct {
 type methodConfig struct {
 	methodHandler
 	opts MethodOpts
+	// method call wrapped with deduplication and interceptors
+	invoke MethodCall
+	// nil if rate limit is not configured
+	rateLimiter *rateLimiter
+	// nil if MaxInFlight is not configured
+	concurrencyLimiter *concurrencyLimiter
+	// true if method is deprecated or it is called by the deprecated alias
+	deprecated bool
 }
 
 type MethodOpts struct {
@@ -84,11 +140,56 @@ type MethodOpts struct {
 	// If true extract value from x-flashbots-origin header
 	// Result can be extracted from the context using GetOrigin
 	ExtractOriginFromHeader bool
+	// Headers that are validated and stored in the context, values can be extracted using GetHeaderValue
+	Headers []HeaderOpts
+	// Names of the function arguments (excluding context) in order.
+	// If set method accepts params as an object, e.g. {"name": "value"}, in addition to the array.
+	// Methods with exactly one struct argument accept object params without this option.
+	ParamNames []string
+	// If not empty request must be authenticated by one of the authenticators, they are tried in order.
+	// Principal can be extracted from the context using GetPrincipal
+	Authenticators []Authenticator
+	// Interceptors called around this method after the interceptors from JSONRPCHandlerOpts
+	Interceptors []Interceptor
+	// If set requests are rate limited per caller, rejected requests get CodeLimitExceeded error
+	RateLimit *RateLimitOpts
+	// If set context passed to the method is cancelled after this duration.
+	// Not applied to subscriptions.
+	Timeout time.Duration
+	// Max number of concurrently executed requests of the method, unlimited if 0
+	MaxInFlight int
+	// When MaxInFlight is reached requests wait for a free slot up to this duration
+	// and get CodeLimitExceeded error after it. If 0 requests are rejected immediately.
+	MaxQueueWait time.Duration
+	// Additional names of the method, e.g. old names of the renamed method
+	Aliases []string
+	// Additional names of the method that are deprecated, the method itself is not deprecated
+	DeprecatedAliases []string
+	// If true calls of the method and all of its aliases are logged and counted as deprecated
+	Deprecated bool
+	// If set HTTP responses to the deprecated method calls have `Warning: 299 - "<DeprecationWarning>"` header,
+	// it is not sent over WebSocket
+	DeprecationWarning string
+	// If not empty method is available only for requests with these URL paths, e.g. "/v1",
+	// otherwise method is available on any path. Methods registered for the path take precedence
+	// over the methods available on any path, so the same name can be registered for different paths
+	// with AddMethod, e.g. to serve different versions of the method on "/v1" and "/v2".
+	Paths []string
+	// If set duplicate calls with the same UniqueKey of the argument get the cached result.
+	// Method must have one argument that implements UniqueKeyer, e.g. *rpctypes.EthSendBundleArgs
+	Dedup *DedupOpts
+	// If true notifications (requests without id) are executed in the background after the response is sent,
+	// otherwise response is sent after method returns. Errors of the background execution are only logged.
+	AsyncNotifications bool
 }
 
 type JSONRPCHandler struct {
 	JSONRPCHandlerOpts
-	methods map[string]methodConfig
+	// maps URL path (anyPath for the methods without MethodOpts.Paths) to the methods by name
+	methods    map[string]map[string]methodConfig
+	methodOpts map[string]MethodOpts
+	// nil if tracing is disabled
+	tracer *tracer
 }
 
 type Methods map[string]any
@@ -100,8 +201,26 @@ type JSONRPCHandlerOpts struct {
 	ServerName string
 	// Max size of the request payload
 	MaxRequestBodySizeBytes int64
+	// Max number of requests in one batch request, DefaultMaxBatchSize is used if 0
+	MaxBatchSize int
+	// Number of requests from one batch that are executed concurrently.
+	// If 0 or 1, requests of the batch are executed sequentially
+	BatchConcurrency int
 	// GET response content
 	GetResponseContent []byte
+	// Interceptors called around every method invocation, the first one is the outermost
+	Interceptors []Interceptor
+	// If set rpc.discover method returns OpenRPC document generated from the registered methods
+	OpenRPC *OpenRPCOpts
+	// If set every HTTP request with its response is written to the capture sink, see Replay
+	Capture *CaptureOpts
+	// If true responses are compressed with gzip or zstd when allowed by the Accept-Encoding header.
+	// Requests with Content-Encoding gzip or zstd are always accepted.
+	CompressResponses bool
+	// Relaxes request validation for non-compliant clients and enables spec-correct error codes
+	Compatibility CompatibilityOpts
+	// If set OpenTelemetry span is created for every JSON-RPC call and passed to the method in the context
+	Tracing *TracingOpts
 }
 
 // NewJSONRPCHandler creates JSONRPC http.Handler from the map that maps method names to method functions
@@ -110,6 +229,8 @@ type JSONRPCHandlerOpts struct {
 // - return error as a last argument
 // - have argument types that can be unmarshalled from JSON
 // - have return types that can be marshalled to JSON
+//
+// Methods of the struct can be registered with RegisterService
 func NewJSONRPCHandler(
 	methods map[string]any,
 	handlerOpts JSONRPCHandlerOpts,
@@ -118,25 +239,200 @@ func NewJSONRPCHandler(
 	if handlerOpts.MaxRequestBodySizeBytes == 0 {
 		handlerOpts.MaxRequestBodySizeBytes = int64(DefaultMaxRequestBodySizeBytes)
 	}
+	if handlerOpts.MaxBatchSize == 0 {
+		handlerOpts.MaxBatchSize = DefaultMaxBatchSize
+	}
 
-	m := make(map[string]methodConfig)
+	h := &JSONRPCHandler{
+		JSONRPCHandlerOpts: handlerOpts,
+		methods:            make(map[string]map[string]methodConfig),
+		methodOpts:         methodOpts,
+		tracer:             newTracer(handlerOpts.Tracing),
+	}
 	for name, fn := range methods {
 		method, err := getMethodTypes(fn)
 		if err != nil {
 			return nil, err
 		}
-		m[name] = methodConfig{
-			methodHandler: method,
-			opts:          methodOpts[name],
+		if err := h.addMethod(name, method, methodOpts[name]); err != nil {
+			return nil, err
 		}
 	}
-	return &JSONRPCHandler{
-		JSONRPCHandlerOpts: handlerOpts,
-		methods:            m,
-	}, nil
+
+	if _, exists := h.methods[anyPath][DiscoverMethod]; handlerOpts.OpenRPC != nil && !exists {
+		method, err := getMethodTypes(h.discover)
+		if err != nil {
+			return nil, err
+		}
+		if err := h.addMethod(DiscoverMethod, method, methodOpts[DiscoverMethod]); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// AddMethod registers the method function with the options, see NewJSONRPCHandler for the function requirements.
+// Unlike NewJSONRPCHandler it allows to register the same name multiple times with different MethodOpts.Paths.
+//
+// AddMethod must be called before handler starts serving requests.
+func (h *JSONRPCHandler) AddMethod(name string, fn any, opts MethodOpts) error {
+	method, err := getMethodTypes(fn)
+	if err != nil {
+		return err
+	}
+	return h.addMethod(name, method, opts)
+}
+
+func (h *JSONRPCHandler) addMethod(name string, method methodHandler, opts MethodOpts) error {
+	registration, err := h.newMethodRegistration(name, method, opts)
+	if err != nil {
+		return err
+	}
+	h.register(registration)
+	return nil
+}
+
+// methodRegistration is a validated method with its aliases that is not added to the handler yet
+type methodRegistration struct {
+	config methodConfig
+	// maps all names of the method to true if the name is deprecated
+	names map[string]bool
+	// normalized paths of the method, anyPath if method is available on any path
+	paths []string
+}
+
+// methodKey identifies registered method
+type methodKey struct {
+	path string
+	name string
+}
+
+func (r *methodRegistration) keys() []methodKey {
+	keys := make([]methodKey, 0, len(r.paths)*len(r.names))
+	for _, path := range r.paths {
+		for name := range r.names {
+			keys = append(keys, methodKey{path: path, name: name})
+		}
+	}
+	return keys
 }
 
-func (h *JSONRPCHandler) writeJSONRPCResponse(w http.ResponseWriter, response jsonRPCResponse) {
+// newMethodRegistration validates method options and checks that its names are not registered
+func (h *JSONRPCHandler) newMethodRegistration(name string, method methodHandler, opts MethodOpts) (*methodRegistration, error) {
+	if err := validateHeaderOpts(opts.Headers); err != nil {
+		return nil, fmt.Errorf("method %s: %w", name, err)
+	}
+	if opts.ParamNames != nil {
+		if err := method.setParamNames(opts.ParamNames); err != nil {
+			return nil, fmt.Errorf("method %s: %w", name, err)
+		}
+	}
+
+	var call MethodCall = func(ctx context.Context, _ string, params []json.RawMessage) (any, error) {
+		return method.call(ctx, params)
+	}
+	config := methodConfig{
+		methodHandler: method,
+		opts:          opts,
+	}
+	if opts.RateLimit != nil {
+		if err := opts.RateLimit.validate(); err != nil {
+			return nil, fmt.Errorf("method %s: %w", name, err)
+		}
+		config.rateLimiter = newRateLimiter(*opts.RateLimit)
+	}
+	if opts.MaxInFlight > 0 {
+		config.concurrencyLimiter = newConcurrencyLimiter(opts.MaxInFlight, opts.MaxQueueWait)
+	}
+	if opts.Dedup != nil {
+		deduplicator, err := newDeduplicator(method, *opts.Dedup)
+		if err != nil {
+			return nil, fmt.Errorf("method %s: %w", name, err)
+		}
+		// subscriptions are not deduplicated, duplicates are checked after the interceptors
+		if !method.isSubscription() {
+			call = deduplicator.call(call, func(method string) { incDuplicateRequest(method, h.ServerName) })
+		}
+	}
+	interceptors := append(slices.Clone(h.Interceptors), opts.Interceptors...)
+	config.invoke = chainInterceptors(interceptors, call)
+
+	// aliases share the limiters with the method
+	names := map[string]bool{name: opts.Deprecated}
+	for _, alias := range opts.Aliases {
+		names[alias] = opts.Deprecated
+	}
+	for _, alias := range opts.DeprecatedAliases {
+		names[alias] = true
+	}
+	paths := []string{anyPath}
+	if len(opts.Paths) > 0 {
+		paths = make([]string, 0, len(opts.Paths))
+		for _, path := range opts.Paths {
+			paths = append(paths, normalizePath(path))
+		}
+	}
+
+	registration := &methodRegistration{config: config, names: names, paths: paths}
+	for _, key := range registration.keys() {
+		if _, exists := h.methods[key.path][key.name]; exists {
+			if key.path == anyPath {
+				return nil, fmt.Errorf("%w: %s", ErrMethodAlreadyRegistered, key.name)
+			}
+			return nil, fmt.Errorf("%w: %s at %s", ErrMethodAlreadyRegistered, key.name, key.path)
+		}
+	}
+	return registration, nil
+}
+
+func (h *JSONRPCHandler) register(registration *methodRegistration) {
+	config := registration.config
+	for _, key := range registration.keys() {
+		if h.methods[key.path] == nil {
+			h.methods[key.path] = make(map[string]methodConfig)
+		}
+		config.deprecated = registration.names[key.name]
+		h.methods[key.path][key.name] = config
+	}
+}
+
+// lookupMethod returns method that can be called with the request to the URL path
+func (h *JSONRPCHandler) lookupMethod(path, name string) (methodConfig, bool) {
+	if config, ok := h.methods[normalizePath(path)][name]; ok {
+		return config, true
+	}
+	config, ok := h.methods[anyPath][name]
+	return config, ok
+}
+
+// methodsAt returns all methods that can be called with the request to the URL path
+func (h *JSONRPCHandler) methodsAt(path string) map[string]methodConfig {
+	methods := maps.Clone(h.methods[anyPath])
+	if methods == nil {
+		methods = make(map[string]methodConfig)
+	}
+	maps.Copy(methods, h.methods[normalizePath(path)])
+	return methods
+}
+
+func normalizePath(path string) string {
+	return "/" + strings.Trim(path, "/")
+}
+
+// requestPath returns URL path of the request that called the method
+func requestPath(ctx context.Context) string {
+	path, _ := ctx.Value(requestPathKey{}).(string)
+	return path
+}
+
+func (h *JSONRPCHandler) logDeprecatedCall(method string) {
+	incDeprecatedCall(method, h.ServerName)
+	if h.Log != nil {
+		h.Log.Warn("deprecated JSON-RPC method called", slog.String("method", method), slog.String("serverName", h.ServerName))
+	}
+}
+
+func (h *JSONRPCHandler) writeJSONRPCResponse(w http.ResponseWriter, response any) {
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		if h.Log != nil {
@@ -149,7 +445,11 @@ func (h *JSONRPCHandler) writeJSONRPCResponse(w http.ResponseWriter, response js
 }
 
 func (h *JSONRPCHandler) writeJSONRPCError(w http.ResponseWriter, id any, code int, msg string) {
-	res := jsonRPCResponse{
+	h.writeJSONRPCResponse(w, newJSONRPCErrorResponse(id, code, msg))
+}
+
+func newJSONRPCErrorResponse(id any, code int, msg string) jsonRPCResponse {
+	return jsonRPCResponse{
 		JSONRPC: "2.0",
 		ID:      id,
 		Result:  nil,
@@ -159,21 +459,44 @@ func (h *JSONRPCHandler) writeJSONRPCError(w http.ResponseWriter, id any, code i
 			Data:    nil,
 		},
 	}
-	h.writeJSONRPCResponse(w, res)
 }
 
-func (h *JSONRPCHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	startAt := time.Now()
-	methodForMetrics := unknownMethodLabel
+// httpRequest holds data of the incoming http request that is shared by all JSON-RPC requests in its body
+type httpRequest struct {
+	*http.Request
+	body []byte
+
+	signatureMu       sync.Mutex
+	signatureVerified bool
+	signer            common.Address
+	signatureErr      error
+}
 
-	ctx := r.Context()
+// verifySignature verifies X-Flashbots-Signature against the whole request body.
+// Result is cached because all requests of the batch share the same signature.
+func (r *httpRequest) verifySignature() (common.Address, error) {
+	r.signatureMu.Lock()
+	defer r.signatureMu.Unlock()
 
-	defer func() {
-		incRequestCount(methodForMetrics, h.ServerName)
-		incRequestDuration(methodForMetrics, time.Since(startAt).Milliseconds(), h.ServerName)
-	}()
+	if !r.signatureVerified {
+		r.signer, r.signatureErr = signature.Verify(r.Header.Get("x-flashbots-signature"), r.body)
+		r.signatureVerified = true
+	}
+	return r.signer, r.signatureErr
+}
+
+// verifiedSigner returns signer if signature was already successfully verified while handling the request
+func (r *httpRequest) verifiedSigner() (common.Address, bool) {
+	r.signatureMu.Lock()
+	defer r.signatureMu.Unlock()
 
+	return r.signer, r.signatureVerified && r.signatureErr == nil
+}
+
+func (h *JSONRPCHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
+		defer incRequestMetrics(unknownMethodLabel, time.Now(), h.ServerName)
+
 		// Respond with GET response content if it's set
 		if r.Method == http.MethodGet && len(h.GetResponseContent) > 0 {
 			w.WriteHeader(http.StatusOK)
@@ -192,59 +515,246 @@ func (h *JSONRPCHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if r.Header.Get("Content-Type") != "application/json" {
+	if !h.Compatibility.isValidContentType(r.Header.Get("Content-Type")) {
+		defer incRequestMetrics(unknownMethodLabel, time.Now(), h.ServerName)
 		http.Error(w, errWrongContentType, http.StatusUnsupportedMediaType)
 		incIncorrectRequest(h.ServerName)
 		return
 	}
 
+	if h.CompressResponses {
+		if encoding := selectResponseEncoding(r.Header.Get("Accept-Encoding")); encoding != "" {
+			cw := newCompressResponseWriter(w, encoding)
+			defer cw.Close()
+			w = cw
+		}
+	}
+
+	startAt := time.Now()
+	// limit is applied both to the compressed and to the decompressed body
 	r.Body = http.MaxBytesReader(w, r.Body, h.MaxRequestBodySizeBytes)
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
+	body, err := readRequestBody(r.Body, r.Header.Get("Content-Encoding"), h.MaxRequestBodySizeBytes)
+	if errors.Is(err, errUnsupportedEncoding) {
+		defer incRequestMetrics(unknownMethodLabel, startAt, h.ServerName)
+		http.Error(w, errUnsupportedContentEncoding, http.StatusUnsupportedMediaType)
+		incIncorrectRequest(h.ServerName)
+		return
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.Is(err, errBodyTooBig) || errors.As(err, &maxBytesErr) {
+		defer incRequestMetrics(unknownMethodLabel, startAt, h.ServerName)
 		msg := fmt.Sprintf("request body is too big, max size: %d", h.MaxRequestBodySizeBytes)
 		h.writeJSONRPCError(w, nil, CodeInvalidRequest, msg)
 		incIncorrectRequest(h.ServerName)
 		return
 	}
+	if err != nil {
+		defer incRequestMetrics(unknownMethodLabel, startAt, h.ServerName)
+		h.writeJSONRPCError(w, nil, CodeParseError, "failed to read request body: "+err.Error())
+		incIncorrectRequest(h.ServerName)
+		return
+	}
+
+	req := &httpRequest{Request: r, body: body}
+	if h.Capture != nil && h.Capture.Sink != nil {
+		cw := newCaptureResponseWriter(w)
+		defer h.captureRecord(req, cw, startAt)
+		w = cw
+	}
+
+	// notifications don't have response
+	res, isNotification := h.handleBody(r.Context(), req, startAt)
+	if res == nil || isNotification {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	setWarningHeaders(w, res)
+	h.writeJSONRPCResponse(w, res)
+}
+
+// setWarningHeaders sets Warning header for every deprecation warning of the response or the batch
+func setWarningHeaders(w http.ResponseWriter, res any) {
+	var responses []jsonRPCResponse
+	switch res := res.(type) {
+	case jsonRPCResponse:
+		responses = []jsonRPCResponse{res}
+	case []jsonRPCResponse:
+		responses = res
+	}
+
+	var warnings []string
+	for _, response := range responses {
+		if response.warning != "" && !slices.Contains(warnings, response.warning) {
+			warnings = append(warnings, response.warning)
+			w.Header().Add("Warning", fmt.Sprintf("299 - %q", response.warning))
+		}
+	}
+}
+
+// handleBody handles body of the request that contains single request or a batch.
+// Returns nil response for the batch of notifications, second return value is true if single request is a notification.
+func (h *JSONRPCHandler) handleBody(ctx context.Context, r *httpRequest, startAt time.Time) (any, bool) {
+	if isBatch(r.body) {
+		return h.handleBatch(ctx, r, startAt), false
+	}
+	return h.handleRequest(ctx, r, r.body, startAt)
+}
+
+// handleBatch handles JSON-RPC batch request, see https://www.jsonrpc.org/specification#batch
+func (h *JSONRPCHandler) handleBatch(ctx context.Context, r *httpRequest, startAt time.Time) any {
+	var batch []json.RawMessage
+	if err := json.Unmarshal(r.body, &batch); err != nil {
+		defer incRequestMetrics(unknownMethodLabel, startAt, h.ServerName)
+		incIncorrectRequest(h.ServerName)
+		return newJSONRPCErrorResponse(nil, CodeParseError, err.Error())
+	}
+	if len(batch) == 0 {
+		defer incRequestMetrics(unknownMethodLabel, startAt, h.ServerName)
+		incIncorrectRequest(h.ServerName)
+		return newJSONRPCErrorResponse(nil, CodeInvalidRequest, "empty batch")
+	}
+	if len(batch) > h.MaxBatchSize {
+		defer incRequestMetrics(unknownMethodLabel, startAt, h.ServerName)
+		msg := fmt.Sprintf("batch is too big, max size: %d", h.MaxBatchSize)
+		incIncorrectRequest(h.ServerName)
+		return newJSONRPCErrorResponse(nil, CodeInvalidRequest, msg)
+	}
+
+	var (
+		responses     = make([]jsonRPCResponse, len(batch))
+		notifications = make([]bool, len(batch))
+	)
+	handleBatchElement := func(i int, rawReq json.RawMessage) {
+		// elements of the batch are valid JSON but each of them must be an object
+		if len(rawReq) == 0 || rawReq[0] != '{' {
+			defer incRequestMetrics(unknownMethodLabel, time.Now(), h.ServerName)
+			responses[i] = newJSONRPCErrorResponse(nil, CodeInvalidRequest, "invalid request")
+			incIncorrectRequest(h.ServerName)
+			return
+		}
+		responses[i], notifications[i] = h.handleRequest(ctx, r, rawReq, time.Now())
+	}
+
+	if h.BatchConcurrency <= 1 {
+		for i, rawReq := range batch {
+			handleBatchElement(i, rawReq)
+		}
+	} else {
+		var (
+			wg        sync.WaitGroup
+			semaphore = make(chan struct{}, h.BatchConcurrency)
+		)
+		for i, rawReq := range batch {
+			wg.Add(1)
+			semaphore <- struct{}{}
+			go func() {
+				defer func() {
+					<-semaphore
+					wg.Done()
+				}()
+				handleBatchElement(i, rawReq)
+			}()
+		}
+		wg.Wait()
+	}
+
+	// responses to notifications must be omitted
+	result := make([]jsonRPCResponse, 0, len(batch))
+	for i, res := range responses {
+		if !notifications[i] {
+			result = append(result, res)
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// handleRequest executes single JSON-RPC request
+// second return value is true if request is a valid notification (request without id) and response must be omitted in batch
+func (h *JSONRPCHandler) handleRequest(ctx context.Context, r *httpRequest, rawReq []byte, startAt time.Time) (res jsonRPCResponse, isNotification bool) {
+	methodForMetrics := unknownMethodLabel
+	defer func() {
+		incRequestMetrics(methodForMetrics, startAt, h.ServerName)
+		incRequestSize(methodForMetrics, len(rawReq), h.ServerName)
+		if res.Error != nil {
+			incErrorCode(methodForMetrics, res.Error.Code, h.ServerName)
+		} else if res.Result != nil {
+			incResponseSize(methodForMetrics, len(*res.Result), h.ServerName)
+		}
+	}()
+
+	ctx, span := h.tracer.startSpan(ctx, r)
+	defer func() {
+		endSpan(ctx, span, &res)
+	}()
 
 	var req jsonRPCRequest
-	if jsonErr := json.Unmarshal(body, &req); jsonErr != nil {
-		h.writeJSONRPCError(w, nil, CodeParseError, jsonErr.Error())
+	if jsonErr := json.Unmarshal(rawReq, &req); jsonErr != nil {
 		incIncorrectRequest(h.ServerName)
-		return
+		return newJSONRPCErrorResponse(nil, h.Compatibility.unmarshalErrorCode(jsonErr), jsonErr.Error()), false
 	}
 
-	methodConfig, exists := h.methods[req.Method]
+	// panic in the method or interceptor must not crash the server
+	defer func() {
+		if rec := recover(); rec != nil {
+			h.handlePanic(req.Method, rec)
+			res = newJSONRPCErrorResponse(req.ID, CodeInternalError, errInternalError)
+			isNotification = req.isNotification()
+		}
+	}()
+
+	methodConfig, exists := h.lookupMethod(r.URL.Path, req.Method)
+	subscriptionNamespace := ""
 	if !exists {
-		h.writeJSONRPCError(w, req.ID, CodeMethodNotFound, "method not found")
-		return
+		if res, ok := h.handleUnsubscribe(ctx, r.URL.Path, &req); ok {
+			return res, req.isNotification()
+		}
+		methodConfig, subscriptionNamespace, exists = h.resolveSubscription(r.URL.Path, &req)
+	}
+	if req.Method == "" && h.Compatibility.Strict {
+		incIncorrectRequest(h.ServerName)
+		return newJSONRPCErrorResponse(req.ID, CodeInvalidRequest, "method is missing"), req.isNotification()
 	}
+	// subscription methods can be called only with <namespace>_subscribe
+	if !exists || (methodConfig.isSubscription() && subscriptionNamespace == "") {
+		return newJSONRPCErrorResponse(req.ID, CodeMethodNotFound, "method not found"), req.isNotification()
+	}
+	ctx = context.WithValue(ctx, requestPathKey{}, r.URL.Path)
+	span.SetName(req.Method)
+	span.SetAttributes(attribute.String("rpc.method", req.Method))
 
 	if methodConfig.opts.VerifyRequestSignatureFromHeader {
-		signatureHeader := r.Header.Get("x-flashbots-signature")
-		signer, verifyErr := signature.Verify(signatureHeader, body)
+		signer, verifyErr := r.verifySignature()
 		if verifyErr != nil {
-			h.writeJSONRPCError(w, nil, CodeInvalidRequest, verifyErr.Error())
 			incIncorrectRequest(h.ServerName)
-			return
+			incSignatureVerificationFailed(req.Method, h.ServerName)
+			var id any
+			if h.Compatibility.Strict {
+				id = req.ID
+			}
+			return newJSONRPCErrorResponse(id, CodeInvalidRequest, verifyErr.Error()), req.isNotification()
 		}
 		ctx = context.WithValue(ctx, signerKey{}, signer)
 	}
 
-	if req.JSONRPC != "2.0" {
-		h.writeJSONRPCError(w, req.ID, CodeParseError, "invalid jsonrpc version")
-		incIncorrectRequest(h.ServerName)
-		return
-	}
-	if req.ID != nil {
-		// id must be string or number
-		switch req.ID.(type) {
-		case string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
-		default:
-			h.writeJSONRPCError(w, req.ID, CodeParseError, "invalid id type")
+	if len(methodConfig.opts.Authenticators) > 0 {
+		principal, authErr := authenticate(methodConfig.opts.Authenticators, r.Request, r.body)
+		if authErr != nil {
 			incIncorrectRequest(h.ServerName)
-			return
+			return newJSONRPCErrorResponse(req.ID, CodeInvalidRequest, authErr.Error()), req.isNotification()
 		}
+		ctx = context.WithValue(ctx, principalKey{}, principal)
+	}
+
+	if !h.Compatibility.isValidVersion(req.JSONRPC) {
+		incIncorrectRequest(h.ServerName)
+		return newJSONRPCErrorResponse(req.ID, h.Compatibility.invalidRequestCode(), "invalid jsonrpc version"), false
+	}
+	if !req.hasValidID() {
+		incIncorrectRequest(h.ServerName)
+		return newJSONRPCErrorResponse(req.ID, h.Compatibility.invalidRequestCode(), "invalid id type"), false
 	}
 
 	if methodConfig.opts.ExtractPriorityFromHeader {
@@ -264,47 +774,139 @@ func (h *JSONRPCHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		origin := r.Header.Get("x-flashbots-origin")
 		if origin != "" {
 			if len(origin) > maxOriginIDLength {
-				h.writeJSONRPCError(w, req.ID, CodeInvalidRequest, "x-flashbots-origin header is too long")
 				incIncorrectRequest(h.ServerName)
-				return
+				return newJSONRPCErrorResponse(req.ID, CodeInvalidRequest, "x-flashbots-origin header is too long"), req.isNotification()
 			}
 			ctx = context.WithValue(ctx, originKey{}, origin)
 		}
 	}
 
-	// get method
-	method, ok := h.methods[req.Method]
-	if !ok {
-		h.writeJSONRPCError(w, req.ID, CodeMethodNotFound, "method not found")
-		incIncorrectRequest(h.ServerName)
-		return
+	if len(methodConfig.opts.Headers) > 0 {
+		var headerErr error
+		ctx, headerErr = extractHeaders(ctx, methodConfig.opts.Headers, r.Header)
+		if headerErr != nil {
+			incIncorrectRequest(h.ServerName)
+			return newJSONRPCErrorResponse(req.ID, CodeInvalidRequest, headerErr.Error()), req.isNotification()
+		}
 	}
 	methodForMetrics = req.Method
 
+	if methodConfig.deprecated {
+		h.logDeprecatedCall(req.Method)
+		if warning := methodConfig.opts.DeprecationWarning; warning != "" {
+			defer func() {
+				res.warning = warning
+			}()
+		}
+	}
+
+	if methodConfig.rateLimiter != nil && !methodConfig.rateLimiter.allow(ctx, r.Request) {
+		incRateLimited(methodForMetrics, h.ServerName)
+		return newJSONRPCErrorResponse(req.ID, CodeLimitExceeded, errRateLimitExceeded), req.isNotification()
+	}
+
+	params, err := methodConfig.positionalParams(req.Params)
+	if err != nil {
+		incRequestErrorCount(methodForMetrics, h.ServerName)
+		return newJSONRPCErrorResponse(req.ID, CodeInvalidParams, err.Error()), req.isNotification()
+	}
+
+	if req.isNotification() && methodConfig.opts.AsyncNotifications && subscriptionNamespace == "" {
+		// response is not needed so method is executed after request is finished
+		go h.callMethodAsync(context.WithoutCancel(ctx), methodConfig, req.Method, params)
+		return jsonRPCResponse{}, true
+	}
+
 	// call method
-	result, err := method.call(ctx, req.Params)
+	var result any
+	if subscriptionNamespace != "" {
+		wsReq := wsRequestFromContext(ctx)
+		if wsReq == nil {
+			return newJSONRPCErrorResponse(req.ID, CodeMethodNotFound, errNotificationsUnsupported), req.isNotification()
+		}
+		result, err = wsReq.subscribe(ctx, subscriptionNamespace, func(ctx context.Context) (any, error) {
+			return h.callMethod(ctx, methodConfig, req.Method, params)
+		})
+	} else {
+		result, err = h.callMethod(ctx, methodConfig, req.Method, params)
+	}
 	if err != nil {
-		h.writeJSONRPCError(w, req.ID, CodeCustomError, err.Error())
 		incRequestErrorCount(methodForMetrics, h.ServerName)
-		return
+		res = jsonRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Result:  nil,
+			Error:   errorToJSONRPCError(err),
+		}
+		return res, req.isNotification()
 	}
 
 	marshaledResult, err := json.Marshal(result)
 	if err != nil {
-		h.writeJSONRPCError(w, req.ID, CodeInternalError, err.Error())
 		incInternalErrors(h.ServerName)
-		return
+		return newJSONRPCErrorResponse(req.ID, CodeInternalError, err.Error()), req.isNotification()
 	}
 
-	// write response
 	rawMessageResult := json.RawMessage(marshaledResult)
-	res := jsonRPCResponse{
+	res = jsonRPCResponse{
 		JSONRPC: "2.0",
 		ID:      req.ID,
 		Result:  &rawMessageResult,
 		Error:   nil,
 	}
-	h.writeJSONRPCResponse(w, res)
+	return res, req.isNotification()
+}
+
+// callMethod calls the method applying in-flight limit and timeout
+func (h *JSONRPCHandler) callMethod(ctx context.Context, config methodConfig, method string, params []json.RawMessage) (any, error) {
+	if limiter := config.concurrencyLimiter; limiter != nil {
+		queuedAt := time.Now()
+		acquired := limiter.acquire(ctx)
+		incQueueWait(method, time.Since(queuedAt), h.ServerName)
+		if !acquired {
+			incServerBusy(method, h.ServerName)
+			return nil, &JSONRPCError{Code: CodeLimitExceeded, Message: errServerBusy}
+		}
+		defer limiter.release()
+	}
+
+	// context of the subscription must live after the call
+	if config.opts.Timeout > 0 && !config.isSubscription() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.opts.Timeout)
+		defer cancel()
+	}
+
+	defer incInFlight(method, h.ServerName)()
+	return config.invoke(ctx, method, params)
+}
+
+// callMethodAsync calls the method in the background for notifications, errors are only logged
+func (h *JSONRPCHandler) callMethodAsync(ctx context.Context, config methodConfig, method string, params []json.RawMessage) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			h.handlePanic(method, rec)
+		}
+	}()
+
+	if _, err := h.callMethod(ctx, config, method, params); err != nil {
+		incRequestErrorCount(method, h.ServerName)
+		if h.Log != nil {
+			h.Log.Debug("async notification failed", slog.Any("error", err), slog.String("method", method), slog.String("serverName", h.ServerName))
+		}
+	}
+}
+
+func (h *JSONRPCHandler) handlePanic(method string, rec any) {
+	if h.Log != nil {
+		h.Log.Error("panic in JSON-RPC method",
+			slog.Any("panic", rec),
+			slog.String("method", method),
+			slog.String("stack", string(debug.Stack())),
+			slog.String("serverName", h.ServerName),
+		)
+	}
+	incInternalErrors(h.ServerName)
 }
 
 func GetHighPriority(ctx context.Context) bool {
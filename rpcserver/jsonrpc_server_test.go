@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/VictoriaMetrics/metrics"
 	"github.com/flashbots/go-utils/rpcclient"
 	"github.com/flashbots/go-utils/signature"
 	"github.com/stretchr/testify/require"
@@ -62,11 +65,11 @@ func TestHandler_ServeHTTP(t *testing.T) {
 		},
 		"invalid params": {
 			requestBody:      `{"jsonrpc":"2.0","id":1,"method":"function","params":[1,2]}`,
-			expectedResponse: `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"too much arguments"}}`, // TODO: return correct code here
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"too much arguments"}}`,
 		},
 		"invalid params type": {
 			requestBody:      `{"jsonrpc":"2.0","id":1,"method":"function","params":["1"]}`,
-			expectedResponse: `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"json: cannot unmarshal string into Go value of type int"}}`,
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"json: cannot unmarshal string into Go value of type int"}}`,
 		},
 	}
 
@@ -129,3 +132,405 @@ func TestJSONRPCServerWithSignatureWithClient(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 123, structResp.Field)
 }
+
+func TestHandler_ServeHTTPBatch(t *testing.T) {
+	testCases := map[string]struct {
+		requestBody      string
+		expectedResponse string
+		expectedCode     int
+	}{
+		"success": {
+			requestBody:      `[{"jsonrpc":"2.0","id":1,"method":"function","params":[1]},{"jsonrpc":"2.0","id":"2","method":"function","params":[2]}]`,
+			expectedResponse: `[{"jsonrpc":"2.0","id":1,"result":{"field":1}},{"jsonrpc":"2.0","id":"2","result":{"field":2}}]`,
+			expectedCode:     http.StatusOK,
+		},
+		"mixed errors": {
+			requestBody:      `[{"jsonrpc":"2.0","id":1,"method":"function","params":[-1]},{"jsonrpc":"2.0","id":2,"method":"not_found"},1]`,
+			expectedResponse: `[{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"custom error"}},{"jsonrpc":"2.0","id":2,"error":{"code":-32601,"message":"method not found"}},{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"invalid request"}}]`,
+			expectedCode:     http.StatusOK,
+		},
+		"notifications are omitted": {
+			requestBody:      `[{"jsonrpc":"2.0","method":"function","params":[1]},{"jsonrpc":"2.0","id":null,"method":"function","params":[2]}]`,
+			expectedResponse: `[{"jsonrpc":"2.0","id":null,"result":{"field":2}}]`,
+			expectedCode:     http.StatusOK,
+		},
+		"only notifications": {
+			requestBody:      `[{"jsonrpc":"2.0","method":"function","params":[1]}]`,
+			expectedResponse: ``,
+			expectedCode:     http.StatusNoContent,
+		},
+		"empty batch": {
+			requestBody:      `[]`,
+			expectedResponse: `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"empty batch"}}`,
+			expectedCode:     http.StatusOK,
+		},
+		"too big batch": {
+			requestBody:      `[{"jsonrpc":"2.0","id":1,"method":"function","params":[1]},{"jsonrpc":"2.0","id":2,"method":"function","params":[2]},{"jsonrpc":"2.0","id":3,"method":"function","params":[3]},{"jsonrpc":"2.0","id":4,"method":"function","params":[4]}]`,
+			expectedResponse: `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"batch is too big, max size: 3"}}`,
+			expectedCode:     http.StatusOK,
+		},
+		"invalid json": {
+			requestBody:      ` [{"jsonrpc":"2.0","id":1,"method":"function","params":[1]}`,
+			expectedResponse: `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"unexpected end of JSON input"}}`,
+			expectedCode:     http.StatusOK,
+		},
+	}
+
+	for _, concurrency := range []int{0, 4} {
+		handler := testHandler(JSONRPCHandlerOpts{MaxBatchSize: 3, BatchConcurrency: concurrency}, nil)
+		for name, testCase := range testCases {
+			t.Run(fmt.Sprintf("%s/concurrency=%d", name, concurrency), func(t *testing.T) {
+				body := bytes.NewReader([]byte(testCase.requestBody))
+				request, err := http.NewRequest(http.MethodPost, "/", body)
+				require.NoError(t, err)
+				request.Header.Add("Content-Type", "application/json")
+
+				rr := httptest.NewRecorder()
+
+				handler.ServeHTTP(rr, request)
+				require.Equal(t, testCase.expectedCode, rr.Code)
+
+				if testCase.expectedResponse == "" {
+					require.Empty(t, rr.Body.String())
+				} else {
+					require.JSONEq(t, testCase.expectedResponse, rr.Body.String())
+				}
+			})
+		}
+	}
+}
+
+func TestJSONRPCServerBatchWithSignatureWithClient(t *testing.T) {
+	handler := testHandler(JSONRPCHandlerOpts{}, map[string]MethodOpts{
+		"function": {VerifyRequestSignatureFromHeader: true},
+	})
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+
+	signer, err := signature.NewRandomSigner()
+	require.NoError(t, err)
+	client := rpcclient.NewClientWithOpts(httpServer.URL, &rpcclient.RPCClientOpts{
+		Signer: signer,
+	})
+
+	responses, err := client.CallBatch(context.Background(), rpcclient.RPCRequests{
+		rpcclient.NewRequest("function", 1),
+		rpcclient.NewRequest("function", 2),
+	})
+	require.NoError(t, err)
+	require.False(t, responses.HasError())
+	require.Len(t, responses, 2)
+	for i, res := range responses {
+		var structResp dummyStruct
+		require.NoError(t, res.GetObject(&structResp))
+		require.Equal(t, i+1, structResp.Field)
+	}
+}
+
+func TestHandler_ServeHTTPObjectParams(t *testing.T) {
+	handler, err := NewJSONRPCHandler(map[string]any{
+		"struct": func(ctx context.Context, arg dummyStruct) (dummyStruct, error) {
+			return arg, nil
+		},
+		"named": func(ctx context.Context, a, b int) (int, error) {
+			return a - b, nil
+		},
+	}, JSONRPCHandlerOpts{}, map[string]MethodOpts{
+		"named": {ParamNames: []string{"a", "b"}},
+	})
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		requestBody      string
+		expectedResponse string
+	}{
+		"struct object params": {
+			requestBody:      `{"jsonrpc":"2.0","id":1,"method":"struct","params":{"field":5}}`,
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"result":{"field":5}}`,
+		},
+		"struct array params": {
+			requestBody:      `{"jsonrpc":"2.0","id":1,"method":"struct","params":[{"field":5}]}`,
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"result":{"field":5}}`,
+		},
+		"named object params": {
+			requestBody:      `{"jsonrpc":"2.0","id":1,"method":"named","params":{"b":1,"a":3}}`,
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"result":2}`,
+		},
+		"named array params": {
+			requestBody:      `{"jsonrpc":"2.0","id":1,"method":"named","params":[3,1]}`,
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"result":2}`,
+		},
+		"named object params with unknown param": {
+			requestBody:      `{"jsonrpc":"2.0","id":1,"method":"named","params":{"c":1}}`,
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"unknown param: c"}}`,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			body := bytes.NewReader([]byte(testCase.requestBody))
+			request, err := http.NewRequest(http.MethodPost, "/", body)
+			require.NoError(t, err)
+			request.Header.Add("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, request)
+			require.Equal(t, http.StatusOK, rr.Code)
+
+			require.JSONEq(t, testCase.expectedResponse, rr.Body.String())
+		})
+	}
+
+	_, err = NewJSONRPCHandler(map[string]any{
+		"named": func(ctx context.Context, a, b int) (int, error) {
+			return a - b, nil
+		},
+	}, JSONRPCHandlerOpts{}, map[string]MethodOpts{
+		"named": {ParamNames: []string{"a"}},
+	})
+	require.ErrorIs(t, err, ErrParamNamesMismatch)
+}
+
+func TestHandler_ServeHTTPPanic(t *testing.T) {
+	var logs bytes.Buffer
+	serverName := "panic_test_server"
+	handler, err := NewJSONRPCHandler(map[string]any{
+		"panic": func(ctx context.Context) error {
+			panic("method panic")
+		},
+	}, JSONRPCHandlerOpts{
+		ServerName:       serverName,
+		BatchConcurrency: 2,
+		Log:              slog.New(slog.NewTextHandler(&logs, nil)),
+	}, nil)
+	require.NoError(t, err)
+
+	internalErrors := metrics.GetOrCreateCounter(fmt.Sprintf(internalErrorsCounter, serverName))
+	internalErrorsBefore := internalErrors.Get()
+
+	testCases := map[string]struct {
+		requestBody      string
+		expectedResponse string
+	}{
+		"single": {
+			requestBody:      `{"jsonrpc":"2.0","id":7,"method":"panic"}`,
+			expectedResponse: `{"jsonrpc":"2.0","id":7,"error":{"code":-32603,"message":"internal error"}}`,
+		},
+		"concurrent batch": {
+			requestBody:      `[{"jsonrpc":"2.0","id":1,"method":"panic"},{"jsonrpc":"2.0","id":2,"method":"panic"}]`,
+			expectedResponse: `[{"jsonrpc":"2.0","id":1,"error":{"code":-32603,"message":"internal error"}},{"jsonrpc":"2.0","id":2,"error":{"code":-32603,"message":"internal error"}}]`,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			body := bytes.NewReader([]byte(testCase.requestBody))
+			request, err := http.NewRequest(http.MethodPost, "/", body)
+			require.NoError(t, err)
+			request.Header.Add("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, request)
+			require.Equal(t, http.StatusOK, rr.Code)
+
+			require.JSONEq(t, testCase.expectedResponse, rr.Body.String())
+		})
+	}
+
+	require.Equal(t, internalErrorsBefore+3, internalErrors.Get())
+	require.Contains(t, logs.String(), "method panic")
+	require.Contains(t, logs.String(), "goroutine")
+}
+
+func TestHandler_ServeHTTPNotifications(t *testing.T) {
+	var (
+		syncCalls = make(chan int, 1)
+		asyncCall = make(chan int, 1)
+		unblock   = make(chan struct{})
+	)
+	handler, err := NewJSONRPCHandler(map[string]any{
+		"sync": func(ctx context.Context, arg int) error {
+			syncCalls <- arg
+			return nil
+		},
+		"async": func(ctx context.Context, arg int) error {
+			<-unblock
+			// context of the finished request must not be cancelled
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			asyncCall <- arg
+			return nil
+		},
+	}, JSONRPCHandlerOpts{}, map[string]MethodOpts{
+		"async": {AsyncNotifications: true},
+	})
+	require.NoError(t, err)
+
+	serve := func(requestBody string) *httptest.ResponseRecorder {
+		request, err := http.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(requestBody)))
+		require.NoError(t, err)
+		request.Header.Add("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, request)
+		return rr
+	}
+
+	rr := serve(`{"jsonrpc":"2.0","method":"sync","params":[1]}`)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	require.Empty(t, rr.Body.String())
+	require.Equal(t, 1, <-syncCalls)
+
+	// response is returned before async method finishes
+	rr = serve(`{"jsonrpc":"2.0","method":"async","params":[2]}`)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	require.Empty(t, rr.Body.String())
+	close(unblock)
+	require.Equal(t, 2, <-asyncCall)
+
+	// async notifications are executed synchronously if request has id
+	rr = serve(`{"jsonrpc":"2.0","id":1,"method":"async","params":[3]}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":null}`, rr.Body.String())
+	require.Equal(t, 3, <-asyncCall)
+
+	rr = serve(`{"jsonrpc":"2.0","method":"not_found"}`)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	require.Empty(t, rr.Body.String())
+
+	// invalid requests are not notifications
+	rr = serve(`{"jsonrpc":"1.0","method":"sync","params":[4]}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"invalid jsonrpc version"}}`, rr.Body.String())
+}
+
+func TestHandler_AliasesAndDeprecation(t *testing.T) {
+	handler := testHandler(JSONRPCHandlerOpts{ServerName: "aliases-test"}, map[string]MethodOpts{
+		"function": {
+			Aliases:            []string{"function_v2"},
+			DeprecatedAliases:  []string{"old_function"},
+			DeprecationWarning: "method is deprecated, use function",
+		},
+	})
+
+	warning := `299 - "method is deprecated, use function"`
+	testCases := map[string]struct {
+		request          string
+		expectedResponse string
+		expectedWarnings []string
+	}{
+		"method": {
+			request:          `{"jsonrpc":"2.0","id":1,"method":"function","params":[1]}`,
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"result":{"field":1}}`,
+		},
+		"alias": {
+			request:          `{"jsonrpc":"2.0","id":1,"method":"function_v2","params":[2]}`,
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"result":{"field":2}}`,
+		},
+		"deprecated alias": {
+			request:          `{"jsonrpc":"2.0","id":1,"method":"old_function","params":[3]}`,
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"result":{"field":3}}`,
+			expectedWarnings: []string{warning},
+		},
+		"deprecated alias error": {
+			request:          `{"jsonrpc":"2.0","id":1,"method":"old_function","params":[-1]}`,
+			expectedResponse: `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"custom error"}}`,
+			expectedWarnings: []string{warning},
+		},
+		"batch": {
+			request:          `[{"jsonrpc":"2.0","id":1,"method":"old_function","params":[4]},{"jsonrpc":"2.0","id":2,"method":"old_function","params":[5]}]`,
+			expectedResponse: `[{"jsonrpc":"2.0","id":1,"result":{"field":4}},{"jsonrpc":"2.0","id":2,"result":{"field":5}}]`,
+			expectedWarnings: []string{warning},
+		},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rr := serveRequest(handler, testCase.request)
+			require.JSONEq(t, testCase.expectedResponse, requireOKResponse(t, rr))
+			require.Equal(t, testCase.expectedWarnings, rr.Header().Values("Warning"))
+		})
+	}
+
+	// warning doesn't break clients that don't allow unknown fields in the response
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+	var result dummyStruct
+	require.NoError(t, rpcclient.NewClient(httpServer.URL).CallFor(context.Background(), &result, "old_function", 6))
+	require.Equal(t, dummyStruct{6}, result)
+
+	var buf bytes.Buffer
+	metrics.WritePrometheus(&buf, false)
+	require.Contains(t, buf.String(), `goutils_rpcserver_deprecated_call_total{method="old_function",server_name="aliases-test"} 5`)
+	require.NotContains(t, buf.String(), `goutils_rpcserver_deprecated_call_total{method="function",server_name="aliases-test"}`)
+
+	// deprecated method is marked in the OpenRPC document
+	methods := make(map[string]bool)
+	for _, method := range handler.OpenRPCDocument().Methods {
+		methods[method.Name] = method.Deprecated
+	}
+	require.Equal(t, map[string]bool{"function": false, "function_v2": false, "old_function": true}, methods)
+
+	// alias can't override another method
+	_, err := NewJSONRPCHandler(map[string]any{
+		"a": func(ctx context.Context) error { return nil },
+		"b": func(ctx context.Context) error { return nil },
+	}, JSONRPCHandlerOpts{}, map[string]MethodOpts{
+		"a": {Aliases: []string{"b"}},
+	})
+	require.ErrorIs(t, err, ErrMethodAlreadyRegistered)
+}
+
+func TestHandler_Paths(t *testing.T) {
+	method := func(ctx context.Context, arg1 int) (dummyStruct, error) {
+		return dummyStruct{arg1}, nil
+	}
+	handler, err := NewJSONRPCHandler(map[string]any{
+		"function":     method,
+		"any_function": method,
+	}, JSONRPCHandlerOpts{}, map[string]MethodOpts{
+		"function": {Paths: []string{"/v1"}},
+	})
+	require.NoError(t, err)
+	// the same name is served by different handler on another path
+	require.NoError(t, handler.AddMethod("function", func(ctx context.Context, arg1 int) (dummyStruct, error) {
+		return dummyStruct{arg1 + 1}, nil
+	}, MethodOpts{Paths: []string{"/v2/"}, Aliases: []string{"v2_function"}}))
+	err = handler.AddMethod("function", method, MethodOpts{Paths: []string{"v1"}})
+	require.ErrorIs(t, err, ErrMethodAlreadyRegistered)
+	// method available on any path is overridden by the path one
+	require.NoError(t, handler.AddMethod("any_function", func(ctx context.Context, arg1 int) (dummyStruct, error) {
+		return dummyStruct{arg1 + 2}, nil
+	}, MethodOpts{Paths: []string{"/v2"}}))
+
+	testCases := []struct {
+		path     string
+		method   string
+		expected string
+	}{
+		{path: "/v1", method: "function", expected: `{"jsonrpc":"2.0","id":1,"result":{"field":1}}`},
+		{path: "/v1/", method: "function", expected: `{"jsonrpc":"2.0","id":1,"result":{"field":1}}`},
+		{path: "/v1", method: "v2_function", expected: `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`},
+		{path: "/v2", method: "function", expected: `{"jsonrpc":"2.0","id":1,"result":{"field":2}}`},
+		{path: "/v2", method: "v2_function", expected: `{"jsonrpc":"2.0","id":1,"result":{"field":2}}`},
+		{path: "/", method: "function", expected: `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`},
+		{path: "/", method: "any_function", expected: `{"jsonrpc":"2.0","id":1,"result":{"field":1}}`},
+		{path: "/v1", method: "any_function", expected: `{"jsonrpc":"2.0","id":1,"result":{"field":1}}`},
+		{path: "/v2", method: "any_function", expected: `{"jsonrpc":"2.0","id":1,"result":{"field":3}}`},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.path+" "+testCase.method, func(t *testing.T) {
+			body := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"%s","params":[1]}`, testCase.method)
+			request, err := http.NewRequest(http.MethodPost, testCase.path, bytes.NewReader([]byte(body)))
+			require.NoError(t, err)
+			request.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, request)
+			require.Equal(t, http.StatusOK, rr.Code)
+			require.JSONEq(t, testCase.expected, rr.Body.String())
+		})
+	}
+}
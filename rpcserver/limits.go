@@ -0,0 +1,49 @@
+package rpcserver
+
+import (
+	"context"
+	"time"
+)
+
+const errServerBusy = "server busy"
+
+// concurrencyLimiter limits number of in-flight requests of the method
+type concurrencyLimiter struct {
+	slots        chan struct{}
+	maxQueueWait time.Duration
+}
+
+func newConcurrencyLimiter(maxInFlight int, maxQueueWait time.Duration) *concurrencyLimiter {
+	return &concurrencyLimiter{
+		slots:        make(chan struct{}, maxInFlight),
+		maxQueueWait: maxQueueWait,
+	}
+}
+
+// acquire takes a slot waiting up to maxQueueWait, returns false if slot was not acquired.
+// release must be called after the method call if slot was acquired.
+func (l *concurrencyLimiter) acquire(ctx context.Context) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if l.maxQueueWait <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(l.maxQueueWait)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (l *concurrencyLimiter) release() {
+	<-l.slots
+}
@@ -0,0 +1,117 @@
+package rpcserver
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func serveTestRequest(t *testing.T, handler http.Handler, requestBody string) string {
+	t.Helper()
+	return requireOKResponse(t, serveRequest(handler, requestBody))
+}
+
+// serveRequest serves the request without assertions, so it can be called from the goroutines started by the test
+func serveRequest(handler http.Handler, requestBody string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(requestBody)))
+	request.Header.Add("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, request)
+	return rr
+}
+
+func requireOKResponse(t *testing.T, rr *httptest.ResponseRecorder) string {
+	t.Helper()
+	require.Equal(t, http.StatusOK, rr.Code)
+	return rr.Body.String()
+}
+
+func TestHandler_Timeout(t *testing.T) {
+	handler, err := NewJSONRPCHandler(map[string]any{
+		"slow": func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}, JSONRPCHandlerOpts{}, map[string]MethodOpts{
+		"slow": {Timeout: 10 * time.Millisecond},
+	})
+	require.NoError(t, err)
+
+	res := serveTestRequest(t, handler, `{"jsonrpc":"2.0","id":1,"method":"slow"}`)
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"context deadline exceeded"}}`, res)
+}
+
+func TestHandler_MaxInFlight(t *testing.T) {
+	var (
+		started = make(chan struct{})
+		unblock = make(chan struct{})
+	)
+	newHandler := func(maxQueueWait time.Duration) *JSONRPCHandler {
+		handler, err := NewJSONRPCHandler(map[string]any{
+			"blocking": func(ctx context.Context) (int, error) {
+				started <- struct{}{}
+				<-unblock
+				return 1, nil
+			},
+		}, JSONRPCHandlerOpts{}, map[string]MethodOpts{
+			"blocking": {MaxInFlight: 1, MaxQueueWait: maxQueueWait},
+		})
+		require.NoError(t, err)
+		return handler
+	}
+	success := `{"jsonrpc":"2.0","id":1,"result":1}`
+	busy := `{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"server busy"}}`
+	request := `{"jsonrpc":"2.0","id":1,"method":"blocking"}`
+
+	t.Run("reject", func(t *testing.T) {
+		handler := newHandler(0)
+		done := make(chan *httptest.ResponseRecorder)
+		go func() {
+			done <- serveRequest(handler, request)
+		}()
+		<-started
+
+		require.JSONEq(t, busy, serveTestRequest(t, handler, request))
+
+		unblock <- struct{}{}
+		require.JSONEq(t, success, requireOKResponse(t, <-done))
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		handler := newHandler(time.Minute)
+		done := make(chan *httptest.ResponseRecorder, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				done <- serveRequest(handler, request)
+			}()
+		}
+
+		// second request waits in the queue until first one finishes
+		<-started
+		unblock <- struct{}{}
+		require.JSONEq(t, success, requireOKResponse(t, <-done))
+		<-started
+		unblock <- struct{}{}
+		require.JSONEq(t, success, requireOKResponse(t, <-done))
+	})
+
+	t.Run("queue timeout", func(t *testing.T) {
+		handler := newHandler(10 * time.Millisecond)
+		done := make(chan *httptest.ResponseRecorder)
+		go func() {
+			done <- serveRequest(handler, request)
+		}()
+		<-started
+
+		require.JSONEq(t, busy, serveTestRequest(t, handler, request))
+
+		unblock <- struct{}{}
+		require.JSONEq(t, success, requireOKResponse(t, <-done))
+	})
+}
@@ -2,6 +2,7 @@ package rpcserver
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/VictoriaMetrics/metrics"
 )
@@ -23,6 +24,28 @@ const (
 	errorCountLabel = `goutils_rpcserver_error_count{method="%s",server_name="%s"}`
 	// total duration of the request
 	requestDurationLabel = `goutils_rpcserver_request_duration_milliseconds{method="%s",server_name="%s"}`
+	// total duration of the request, histogram can be aggregated across instances unlike the summary
+	requestDurationHistogram = `goutils_rpcserver_request_duration_histogram_milliseconds{method="%s",server_name="%s"}`
+	// incremented for every error response with the JSON-RPC error code
+	errorCodeCounter = `goutils_rpcserver_error_code_total{method="%s",server_name="%s",code="%d"}`
+	// number of requests that are currently executed by the method
+	inFlightGauge = `goutils_rpcserver_in_flight_requests{method="%s",server_name="%s"}`
+	// size of the JSON-RPC request
+	requestSizeHistogram = `goutils_rpcserver_request_size_bytes{method="%s",server_name="%s"}`
+	// size of the result of the successful response
+	responseSizeHistogram = `goutils_rpcserver_response_size_bytes{method="%s",server_name="%s"}`
+	// incremented when X-Flashbots-Signature header is missing or invalid for the method that requires it
+	signatureVerificationFailedCounter = `goutils_rpcserver_signature_verification_failed_total{method="%s",server_name="%s"}`
+	// incremented when deprecated method or deprecated alias is called
+	deprecatedCallCounter = `goutils_rpcserver_deprecated_call_total{method="%s",server_name="%s"}`
+	// incremented when duplicate request gets cached result
+	duplicateRequestCounter = `goutils_rpcserver_duplicate_request_total{method="%s",server_name="%s"}`
+	// incremented when request is rejected by the rate limiter
+	rateLimitedCounter = `goutils_rpcserver_rate_limited_total{method="%s",server_name="%s"}`
+	// incremented when request is rejected because method has too many requests in flight
+	serverBusyCounter = `goutils_rpcserver_server_busy_total{method="%s",server_name="%s"}`
+	// time spent waiting for the free slot of the method with in-flight limit
+	queueWaitHistogram = `goutils_rpcserver_queue_wait_milliseconds{method="%s",server_name="%s"}`
 )
 
 func incRequestCount(method, serverName string) {
@@ -30,6 +53,11 @@ func incRequestCount(method, serverName string) {
 	metrics.GetOrCreateCounter(l).Inc()
 }
 
+func incRequestMetrics(method string, startAt time.Time, serverName string) {
+	incRequestCount(method, serverName)
+	incRequestDuration(method, time.Since(startAt), serverName)
+}
+
 func incIncorrectRequest(serverName string) {
 	l := fmt.Sprintf(incorrectRequestCounter, serverName)
 	metrics.GetOrCreateCounter(l).Inc()
@@ -40,12 +68,67 @@ func incRequestErrorCount(method, serverName string) {
 	metrics.GetOrCreateCounter(l).Inc()
 }
 
-func incRequestDuration(method string, duration int64, serverName string) {
+func incRequestDuration(method string, duration time.Duration, serverName string) {
 	l := fmt.Sprintf(requestDurationLabel, method, serverName)
-	metrics.GetOrCreateSummary(l).Update(float64(duration))
+	metrics.GetOrCreateSummary(l).Update(float64(duration.Milliseconds()))
+	l = fmt.Sprintf(requestDurationHistogram, method, serverName)
+	metrics.GetOrCreateHistogram(l).Update(float64(duration.Microseconds()) / 1000)
+}
+
+func incErrorCode(method string, code int, serverName string) {
+	l := fmt.Sprintf(errorCodeCounter, method, serverName, code)
+	metrics.GetOrCreateCounter(l).Inc()
+}
+
+// incInFlight increments in-flight gauge of the method, returned function decrements it
+func incInFlight(method, serverName string) func() {
+	l := fmt.Sprintf(inFlightGauge, method, serverName)
+	gauge := metrics.GetOrCreateGauge(l, nil)
+	gauge.Inc()
+	return gauge.Dec
+}
+
+func incRequestSize(method string, size int, serverName string) {
+	l := fmt.Sprintf(requestSizeHistogram, method, serverName)
+	metrics.GetOrCreateHistogram(l).Update(float64(size))
+}
+
+func incResponseSize(method string, size int, serverName string) {
+	l := fmt.Sprintf(responseSizeHistogram, method, serverName)
+	metrics.GetOrCreateHistogram(l).Update(float64(size))
+}
+
+func incSignatureVerificationFailed(method, serverName string) {
+	l := fmt.Sprintf(signatureVerificationFailedCounter, method, serverName)
+	metrics.GetOrCreateCounter(l).Inc()
 }
 
 func incInternalErrors(serverName string) {
 	l := fmt.Sprintf(internalErrorsCounter, serverName)
 	metrics.GetOrCreateCounter(l).Inc()
 }
+
+func incRateLimited(method, serverName string) {
+	l := fmt.Sprintf(rateLimitedCounter, method, serverName)
+	metrics.GetOrCreateCounter(l).Inc()
+}
+
+func incServerBusy(method, serverName string) {
+	l := fmt.Sprintf(serverBusyCounter, method, serverName)
+	metrics.GetOrCreateCounter(l).Inc()
+}
+
+func incQueueWait(method string, duration time.Duration, serverName string) {
+	l := fmt.Sprintf(queueWaitHistogram, method, serverName)
+	metrics.GetOrCreateHistogram(l).Update(float64(duration.Microseconds()) / 1000)
+}
+
+func incDeprecatedCall(method, serverName string) {
+	l := fmt.Sprintf(deprecatedCallCounter, method, serverName)
+	metrics.GetOrCreateCounter(l).Inc()
+}
+
+func incDuplicateRequest(method, serverName string) {
+	l := fmt.Sprintf(duplicateRequestCounter, method, serverName)
+	metrics.GetOrCreateCounter(l).Inc()
+}
@@ -0,0 +1,71 @@
+package rpcserver
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func writeMetrics(t *testing.T) string {
+	t.Helper()
+	var buf bytes.Buffer
+	metrics.WritePrometheus(&buf, false)
+	return buf.String()
+}
+
+func TestHandler_Metrics(t *testing.T) {
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	handler, err := NewJSONRPCHandler(map[string]any{
+		"function": func(ctx context.Context, arg1 int) (int, error) {
+			return arg1, nil
+		},
+		"failing": func(ctx context.Context) error {
+			return &JSONRPCError{Code: -32042, Message: "failed"}
+		},
+		"signed": func(ctx context.Context) error {
+			return nil
+		},
+		"blocking": func(ctx context.Context) error {
+			close(started)
+			<-unblock
+			return nil
+		},
+	}, JSONRPCHandlerOpts{ServerName: "metrics-test"}, map[string]MethodOpts{
+		"signed": {VerifyRequestSignatureFromHeader: true},
+	})
+	require.NoError(t, err)
+
+	serveTestRequest(t, handler, `{"jsonrpc":"2.0","id":1,"method":"function","params":[123]}`)
+	serveTestRequest(t, handler, `{"jsonrpc":"2.0","id":1,"method":"failing"}`)
+	serveTestRequest(t, handler, `{"jsonrpc":"2.0","id":1,"method":"not_registered"}`)
+	serveTestRequest(t, handler, `{"jsonrpc":"2.0","id":1,"method":"signed"}`)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- serveRequest(handler, `{"jsonrpc":"2.0","id":1,"method":"blocking"}`)
+	}()
+	<-started
+	require.Contains(t, writeMetrics(t), `goutils_rpcserver_in_flight_requests{method="blocking",server_name="metrics-test"} 1`)
+	close(unblock)
+	requireOKResponse(t, <-done)
+	require.Contains(t, writeMetrics(t), `goutils_rpcserver_in_flight_requests{method="blocking",server_name="metrics-test"} 0`)
+
+	output := writeMetrics(t)
+	for _, expected := range []string{
+		`goutils_rpcserver_request_duration_histogram_milliseconds_count{method="function",server_name="metrics-test"} 1`,
+		`goutils_rpcserver_request_size_bytes_sum{method="function",server_name="metrics-test"} 59`,
+		`goutils_rpcserver_response_size_bytes_sum{method="function",server_name="metrics-test"} 3`,
+		`goutils_rpcserver_error_code_total{method="failing",server_name="metrics-test",code="-32042"} 1`,
+		`goutils_rpcserver_signature_verification_failed_total{method="signed",server_name="metrics-test"} 1`,
+		// unregistered methods are not used as labels
+		`goutils_rpcserver_error_code_total{method="unknown",server_name="metrics-test",code="-32601"} 1`,
+	} {
+		require.Contains(t, output, expected)
+	}
+	require.NotContains(t, output, "not_registered")
+}
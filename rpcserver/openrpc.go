@@ -0,0 +1,275 @@
+package rpcserver
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DiscoverMethod is a method that returns OpenRPC document, see https://spec.open-rpc.org/#service-discovery-method
+	DiscoverMethod = "rpc.discover"
+
+	openRPCVersion = "1.2.6"
+)
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+
+	invalidSchemaNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+)
+
+// OpenRPCOpts enables rpc.discover method that returns OpenRPC document describing registered methods
+type OpenRPCOpts struct {
+	// Title of the API, ServerName is used if empty
+	Title string
+	// Version of the API
+	Version string
+	// Description of the API, can be empty
+	Description string
+}
+
+// OpenRPCDocument is a subset of OpenRPC document, see https://spec.open-rpc.org
+type OpenRPCDocument struct {
+	OpenRPC    string            `json:"openrpc"`
+	Info       OpenRPCInfo       `json:"info"`
+	Methods    []OpenRPCMethod   `json:"methods"`
+	Components OpenRPCComponents `json:"components"`
+}
+
+type OpenRPCInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type OpenRPCMethod struct {
+	Name           string                     `json:"name"`
+	ParamStructure string                     `json:"paramStructure,omitempty"`
+	Params         []OpenRPCContentDescriptor `json:"params"`
+	Result         *OpenRPCContentDescriptor  `json:"result,omitempty"`
+	Deprecated     bool                       `json:"deprecated,omitempty"`
+}
+
+type OpenRPCContentDescriptor struct {
+	Name   string      `json:"name"`
+	Schema *JSONSchema `json:"schema"`
+}
+
+type OpenRPCComponents struct {
+	Schemas map[string]*JSONSchema `json:"schemas,omitempty"`
+}
+
+// JSONSchema is a subset of JSON Schema used to describe params and results
+type JSONSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+}
+
+// OpenRPCDocument generates OpenRPC document from the argument and return types of the methods
+// available at the root path, see OpenRPCDocumentAt. Subscription methods are not included.
+func (h *JSONRPCHandler) OpenRPCDocument() *OpenRPCDocument {
+	return h.OpenRPCDocumentAt("/")
+}
+
+// OpenRPCDocumentAt generates OpenRPC document of the methods available for requests to the URL path
+func (h *JSONRPCHandler) OpenRPCDocumentAt(path string) *OpenRPCDocument {
+	opts := OpenRPCOpts{}
+	if h.OpenRPC != nil {
+		opts = *h.OpenRPC
+	}
+	if opts.Title == "" {
+		opts.Title = h.ServerName
+	}
+
+	available := h.methodsAt(path)
+	names := make([]string, 0, len(available))
+	for name, method := range available {
+		if name == DiscoverMethod || method.isSubscription() {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	g := newSchemaGenerator()
+	methods := make([]OpenRPCMethod, 0, len(names))
+	for _, name := range names {
+		methods = append(methods, g.method(name, available[name]))
+	}
+
+	return &OpenRPCDocument{
+		OpenRPC: openRPCVersion,
+		Info: OpenRPCInfo{
+			Title:       opts.Title,
+			Version:     opts.Version,
+			Description: opts.Description,
+		},
+		Methods:    methods,
+		Components: OpenRPCComponents{Schemas: g.schemas},
+	}
+}
+
+func (h *JSONRPCHandler) discover(ctx context.Context) (*OpenRPCDocument, error) {
+	return h.OpenRPCDocumentAt(requestPath(ctx)), nil
+}
+
+// schemaGenerator creates schemas of the types, named structs are put into components and referenced
+type schemaGenerator struct {
+	schemas map[string]*JSONSchema
+	names   map[reflect.Type]string
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{
+		schemas: make(map[string]*JSONSchema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+func (g *schemaGenerator) method(name string, config methodConfig) OpenRPCMethod {
+	method := OpenRPCMethod{
+		Name:           name,
+		ParamStructure: "by-position",
+		Params:         make([]OpenRPCContentDescriptor, 0, len(config.in)-1),
+		Deprecated:     config.deprecated,
+	}
+	if config.paramNames != nil {
+		method.ParamStructure = "either"
+	}
+
+	for i, argType := range config.in[1:] {
+		argName := "arg" + strconv.Itoa(i)
+		if config.paramNames != nil {
+			argName = config.paramNames[i]
+		}
+		method.Params = append(method.Params, OpenRPCContentDescriptor{
+			Name:   argName,
+			Schema: g.schema(argType),
+		})
+	}
+
+	result := &OpenRPCContentDescriptor{
+		Name:   "result",
+		Schema: &JSONSchema{Type: "null"},
+	}
+	if len(config.out) == 2 {
+		result.Schema = g.schema(config.out[0])
+	}
+	method.Result = result
+	return method
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) *JSONSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// custom JSON encoding can't be described
+		return &JSONSchema{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return &JSONSchema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			// []byte is encoded as base64 string
+			return &JSONSchema{Type: "string", Format: "byte"}
+		}
+		return &JSONSchema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return &JSONSchema{Ref: "#/components/schemas/" + g.structName(t)}
+	default:
+		// interfaces can hold any value
+		return &JSONSchema{}
+	}
+}
+
+// structName returns name of the struct in components, schema is generated on the first use
+func (g *schemaGenerator) structName(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	baseName := invalidSchemaNameChars.ReplaceAllString(t.Name(), "_")
+	name := baseName
+	for i := 2; g.schemas[name] != nil; i++ {
+		name = baseName + strconv.Itoa(i)
+	}
+
+	// register name before generating schema to support recursive types
+	g.names[t] = name
+	g.schemas[name] = &JSONSchema{}
+	*g.schemas[name] = *g.structSchema(t)
+	return name
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *JSONSchema {
+	schema := &JSONSchema{
+		Type:       "object",
+		Properties: make(map[string]*JSONSchema),
+	}
+	g.addStructFields(schema, t)
+	return schema
+}
+
+func (g *schemaGenerator) addStructFields(schema *JSONSchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		// fields of embedded structs without name are promoted
+		if field.Anonymous && name == "" {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				g.addStructFields(schema, fieldType)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = g.schema(field.Type)
+	}
+}
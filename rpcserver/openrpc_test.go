@@ -0,0 +1,114 @@
+package rpcserver
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/flashbots/go-utils/rpcclient"
+	"github.com/flashbots/go-utils/rpctypes"
+	"github.com/stretchr/testify/require"
+)
+
+type ethSendBundleResult struct {
+	BundleHash common.Hash `json:"bundleHash"`
+}
+
+func TestOpenRPCDocument(t *testing.T) {
+	handler, err := NewJSONRPCHandler(map[string]any{
+		"eth_sendBundle": func(ctx context.Context, args rpctypes.EthSendBundleArgs) (ethSendBundleResult, error) {
+			return ethSendBundleResult{}, nil
+		},
+		"mev_sendBundle": func(ctx context.Context, args rpctypes.MevSendBundleArgs) error {
+			return nil
+		},
+		"test_named": func(ctx context.Context, a int, b []string, c map[string]bool) (*dummyStruct, error) {
+			return nil, nil
+		},
+		"test_subscription": func(ctx context.Context) (<-chan int, error) {
+			return nil, nil
+		},
+	}, JSONRPCHandlerOpts{
+		ServerName: "test_server",
+		OpenRPC:    &OpenRPCOpts{Version: "1.0.0"},
+	}, map[string]MethodOpts{
+		"test_named": {ParamNames: []string{"a", "b", "c"}},
+	})
+	require.NoError(t, err)
+
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+
+	client := rpcclient.NewClient(httpServer.URL)
+	var doc OpenRPCDocument
+	err = client.CallFor(context.Background(), &doc, DiscoverMethod)
+	require.NoError(t, err)
+
+	require.Equal(t, openRPCVersion, doc.OpenRPC)
+	require.Equal(t, OpenRPCInfo{Title: "test_server", Version: "1.0.0"}, doc.Info)
+
+	require.Len(t, doc.Methods, 3)
+	require.Equal(t, "eth_sendBundle", doc.Methods[0].Name)
+	require.Equal(t, "mev_sendBundle", doc.Methods[1].Name)
+	require.Equal(t, "test_named", doc.Methods[2].Name)
+
+	sendBundle := doc.Methods[0]
+	require.Equal(t, "by-position", sendBundle.ParamStructure)
+	require.Len(t, sendBundle.Params, 1)
+	require.Equal(t, "#/components/schemas/EthSendBundleArgs", sendBundle.Params[0].Schema.Ref)
+	require.Equal(t, "#/components/schemas/ethSendBundleResult", sendBundle.Result.Schema.Ref)
+
+	bundleSchema := doc.Components.Schemas["EthSendBundleArgs"]
+	require.NotNil(t, bundleSchema)
+	require.Equal(t, "object", bundleSchema.Type)
+	require.Equal(t, &JSONSchema{Type: "array", Items: &JSONSchema{Type: "string"}}, bundleSchema.Properties["txs"])
+	require.Equal(t, &JSONSchema{Type: "string"}, bundleSchema.Properties["blockNumber"])
+	require.Equal(t, &JSONSchema{Type: "integer"}, bundleSchema.Properties["minTimestamp"])
+
+	// recursive type
+	require.Equal(t, "null", doc.Methods[1].Result.Schema.Type)
+	bodySchema := doc.Components.Schemas["MevBundleBody"]
+	require.NotNil(t, bodySchema)
+	require.Equal(t, "#/components/schemas/MevSendBundleArgs", bodySchema.Properties["bundle"].Ref)
+
+	named := doc.Methods[2]
+	require.Equal(t, "either", named.ParamStructure)
+	require.Equal(t, []OpenRPCContentDescriptor{
+		{Name: "a", Schema: &JSONSchema{Type: "integer"}},
+		{Name: "b", Schema: &JSONSchema{Type: "array", Items: &JSONSchema{Type: "string"}}},
+		{Name: "c", Schema: &JSONSchema{Type: "object", AdditionalProperties: &JSONSchema{Type: "boolean"}}},
+	}, named.Params)
+	require.Equal(t, "#/components/schemas/dummyStruct", named.Result.Schema.Ref)
+}
+
+func TestOpenRPCDocument_Paths(t *testing.T) {
+	method := func(ctx context.Context) error { return nil }
+	handler, err := NewJSONRPCHandler(map[string]any{
+		"any_function": method,
+		"v1_function":  method,
+	}, JSONRPCHandlerOpts{
+		OpenRPC: &OpenRPCOpts{},
+	}, map[string]MethodOpts{
+		"v1_function": {Paths: []string{"/v1"}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, handler.AddMethod("v2_function", method, MethodOpts{Paths: []string{"/v2"}}))
+
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+
+	methodNames := func(path string) []string {
+		var doc OpenRPCDocument
+		err := rpcclient.NewClient(httpServer.URL+path).CallFor(context.Background(), &doc, DiscoverMethod)
+		require.NoError(t, err)
+		names := make([]string, 0, len(doc.Methods))
+		for _, method := range doc.Methods {
+			names = append(names, method.Name)
+		}
+		return names
+	}
+	require.Equal(t, []string{"any_function"}, methodNames("/"))
+	require.Equal(t, []string{"any_function", "v1_function"}, methodNames("/v1"))
+	require.Equal(t, []string{"any_function", "v2_function"}, methodNames("/v2/"))
+}
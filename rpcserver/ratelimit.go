@@ -0,0 +1,154 @@
+package rpcserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitKey selects the value used to identify the caller for rate limiting
+type RateLimitKey int
+
+const (
+	// RateLimitBySigner uses signer address from X-Flashbots-Signature.
+	// Signer must be extracted by MethodOpts.VerifyRequestSignatureFromHeader, unverified signer can be spoofed.
+	// Requests without signer are limited by IP.
+	RateLimitBySigner RateLimitKey = iota
+	// RateLimitByOrigin uses value of the x-flashbots-origin header.
+	// Requests without origin are limited by IP.
+	RateLimitByOrigin
+	// RateLimitByIP uses remote IP of the request
+	RateLimitByIP
+)
+
+const (
+	errRateLimitExceeded = "rate limit exceeded"
+
+	// idle limiters are removed at most once per this interval
+	rateLimiterCleanupInterval = time.Minute
+)
+
+// RateLimitOpts configures token bucket rate limiter of the method
+type RateLimitOpts struct {
+	// Value used to identify the caller
+	Key RateLimitKey
+	// Number of requests per second allowed for one caller
+	RequestsPerSecond float64
+	// Max number of requests allowed at once for one caller
+	Burst int
+	// If set remote IP is taken from this header (e.g. X-Forwarded-For) instead of the remote address.
+	// The last value of the header is used because values before it are set by the client and can be spoofed,
+	// so it must only be used behind one proxy that appends the remote address to this header.
+	IPHeader string
+}
+
+func (o *RateLimitOpts) validate() error {
+	if o.RequestsPerSecond <= 0 {
+		return errors.New("rate limit: requests per second must be positive")
+	}
+	if o.Burst < 1 {
+		return errors.New("rate limit: burst must be at least 1")
+	}
+	return nil
+}
+
+type rateLimiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter holds token buckets of all callers of one method
+type rateLimiter struct {
+	opts RateLimitOpts
+
+	mu          sync.Mutex
+	limiters    map[string]*rateLimiterEntry
+	lastCleanup time.Time
+}
+
+func newRateLimiter(opts RateLimitOpts) *rateLimiter {
+	return &rateLimiter{
+		opts:        opts,
+		limiters:    make(map[string]*rateLimiterEntry),
+		lastCleanup: time.Now(),
+	}
+}
+
+// allow takes a token from the bucket of the caller and returns false if bucket is empty
+func (l *rateLimiter) allow(ctx context.Context, r *http.Request) bool {
+	key := l.key(ctx, r)
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cleanup(now)
+
+	entry, ok := l.limiters[key]
+	if !ok {
+		entry = &rateLimiterEntry{limiter: rate.NewLimiter(rate.Limit(l.opts.RequestsPerSecond), l.opts.Burst)}
+		l.limiters[key] = entry
+	}
+	entry.lastSeen = now
+	return entry.limiter.AllowN(now, 1)
+}
+
+// cleanup removes limiters that were idle long enough to refill the bucket, they are equivalent to new ones
+func (l *rateLimiter) cleanup(now time.Time) {
+	if now.Sub(l.lastCleanup) < rateLimiterCleanupInterval {
+		return
+	}
+	l.lastCleanup = now
+
+	refillDuration := rateLimiterCleanupInterval
+	if l.opts.RequestsPerSecond > 0 {
+		refillDuration = max(refillDuration, time.Duration(float64(l.opts.Burst)/l.opts.RequestsPerSecond*float64(time.Second)))
+	}
+	for key, entry := range l.limiters {
+		if now.Sub(entry.lastSeen) > refillDuration {
+			delete(l.limiters, key)
+		}
+	}
+}
+
+func (l *rateLimiter) key(ctx context.Context, r *http.Request) string {
+	switch l.opts.Key {
+	case RateLimitBySigner:
+		if signer := GetSigner(ctx); signer != (common.Address{}) {
+			return "signer:" + signer.Hex()
+		}
+	case RateLimitByOrigin:
+		if origin := GetOrigin(ctx); origin != "" {
+			return "origin:" + origin
+		}
+	case RateLimitByIP:
+	}
+	return "ip:" + l.remoteIP(r)
+}
+
+func (l *rateLimiter) remoteIP(r *http.Request) string {
+	if l.opts.IPHeader != "" {
+		// header can be repeated, the last value is appended by the proxy
+		if values := r.Header.Values(l.opts.IPHeader); len(values) > 0 {
+			value := values[len(values)-1]
+			if i := strings.LastIndex(value, ","); i >= 0 {
+				value = value[i+1:]
+			}
+			if ip := strings.TrimSpace(value); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
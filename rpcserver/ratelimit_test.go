@@ -0,0 +1,129 @@
+package rpcserver
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/flashbots/go-utils/rpcclient"
+	"github.com/flashbots/go-utils/signature"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiterKey(t *testing.T) {
+	request, err := http.NewRequest(http.MethodPost, "/", nil)
+	require.NoError(t, err)
+	request.RemoteAddr = "10.0.0.1:1234"
+	request.Header.Set("X-Forwarded-For", "10.0.0.2, 10.0.0.3")
+
+	signer := common.HexToAddress("0x1")
+	ctx := context.WithValue(context.Background(), signerKey{}, signer)
+	ctx = context.WithValue(ctx, originKey{}, "origin")
+
+	require.Equal(t, "signer:"+signer.Hex(), newRateLimiter(RateLimitOpts{Key: RateLimitBySigner}).key(ctx, request))
+	require.Equal(t, "ip:10.0.0.1", newRateLimiter(RateLimitOpts{Key: RateLimitBySigner}).key(context.Background(), request))
+	require.Equal(t, "origin:origin", newRateLimiter(RateLimitOpts{Key: RateLimitByOrigin}).key(ctx, request))
+	require.Equal(t, "ip:10.0.0.1", newRateLimiter(RateLimitOpts{Key: RateLimitByIP}).key(ctx, request))
+	// the last value is set by the proxy, previous ones can be spoofed by the client
+	require.Equal(t, "ip:10.0.0.3", newRateLimiter(RateLimitOpts{Key: RateLimitByIP, IPHeader: "X-Forwarded-For"}).key(ctx, request))
+	request.Header.Add("X-Forwarded-For", "10.0.0.4")
+	require.Equal(t, "ip:10.0.0.4", newRateLimiter(RateLimitOpts{Key: RateLimitByIP, IPHeader: "X-Forwarded-For"}).key(ctx, request))
+}
+
+func TestRateLimitOpts_Validate(t *testing.T) {
+	testCases := map[string]struct {
+		opts  RateLimitOpts
+		valid bool
+	}{
+		"valid":        {opts: RateLimitOpts{RequestsPerSecond: 0.5, Burst: 1}, valid: true},
+		"zero burst":   {opts: RateLimitOpts{RequestsPerSecond: 1, Burst: 0}},
+		"zero rps":     {opts: RateLimitOpts{RequestsPerSecond: 0, Burst: 1}},
+		"negative rps": {opts: RateLimitOpts{RequestsPerSecond: -1, Burst: 1}},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewJSONRPCHandler(Methods{"function": func(ctx context.Context) error { return nil }},
+				JSONRPCHandlerOpts{}, map[string]MethodOpts{"function": {RateLimit: &testCase.opts}})
+			if testCase.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestRateLimiterCleanup(t *testing.T) {
+	limiter := newRateLimiter(RateLimitOpts{Key: RateLimitByIP, RequestsPerSecond: 1, Burst: 1})
+	request, err := http.NewRequest(http.MethodPost, "/", nil)
+	require.NoError(t, err)
+	request.RemoteAddr = "10.0.0.1:1234"
+
+	require.True(t, limiter.allow(context.Background(), request))
+	require.False(t, limiter.allow(context.Background(), request))
+	require.Len(t, limiter.limiters, 1)
+
+	limiter.cleanup(time.Now().Add(2 * rateLimiterCleanupInterval))
+	require.Empty(t, limiter.limiters)
+}
+
+func TestHandler_RateLimitByIP(t *testing.T) {
+	handler := testHandler(JSONRPCHandlerOpts{}, map[string]MethodOpts{
+		"function": {RateLimit: &RateLimitOpts{Key: RateLimitByIP, RequestsPerSecond: 0.001, Burst: 2}},
+	})
+
+	call := func(remoteAddr string) string {
+		body := bytes.NewReader([]byte(`{"jsonrpc":"2.0","id":1,"method":"function","params":[1]}`))
+		request, err := http.NewRequest(http.MethodPost, "/", body)
+		require.NoError(t, err)
+		request.Header.Add("Content-Type", "application/json")
+		request.RemoteAddr = remoteAddr
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, request)
+		require.Equal(t, http.StatusOK, rr.Code)
+		return rr.Body.String()
+	}
+
+	success := `{"jsonrpc":"2.0","id":1,"result":{"field":1}}`
+	limited := `{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"rate limit exceeded"}}`
+
+	require.JSONEq(t, success, call("10.0.0.1:1"))
+	require.JSONEq(t, success, call("10.0.0.1:2"))
+	require.JSONEq(t, limited, call("10.0.0.1:3"))
+	require.JSONEq(t, success, call("10.0.0.2:1"))
+}
+
+func TestJSONRPCServerRateLimitBySignerWithClient(t *testing.T) {
+	handler := testHandler(JSONRPCHandlerOpts{}, map[string]MethodOpts{
+		"function": {
+			VerifyRequestSignatureFromHeader: true,
+			RateLimit:                        &RateLimitOpts{Key: RateLimitBySigner, RequestsPerSecond: 0.001, Burst: 1},
+		},
+	})
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+
+	newClient := func() rpcclient.RPCClient {
+		signer, err := signature.NewRandomSigner()
+		require.NoError(t, err)
+		return rpcclient.NewClientWithOpts(httpServer.URL, &rpcclient.RPCClientOpts{
+			Signer: signer,
+		})
+	}
+	client1, client2 := newClient(), newClient()
+
+	var resp dummyStruct
+	require.NoError(t, client1.CallFor(context.Background(), &resp, "function", 1))
+
+	err := client1.CallFor(context.Background(), &resp, "function", 1)
+	var rpcErr *rpcclient.RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, CodeLimitExceeded, rpcErr.Code)
+
+	require.NoError(t, client2.CallFor(context.Background(), &resp, "function", 1))
+}
@@ -1,10 +1,13 @@
 package rpcserver
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"reflect"
+	"slices"
 )
 
 var (
@@ -14,12 +17,20 @@ var (
 	ErrTooManyReturnValues = errors.New("too many return values")
 
 	ErrTooMuchArguments = errors.New("too much arguments")
+
+	ErrParamNamesMismatch       = errors.New("number of param names must match number of function arguments")
+	ErrObjectParamsNotSupported = errors.New("method does not support object params")
+	ErrUnknownParam             = errors.New("unknown param")
+	ErrInvalidParams            = errors.New("params must be an array or an object")
 )
 
 type methodHandler struct {
 	in  []reflect.Type
 	out []reflect.Type
 	fn  any
+
+	// names of the arguments (excluding context) used to bind object params
+	paramNames []string
 }
 
 func getMethodTypes(fn interface{}) (methodHandler, error) {
@@ -53,13 +64,85 @@ func getMethodTypes(fn interface{}) (methodHandler, error) {
 		return methodHandler{}, ErrTooManyReturnValues
 	}
 
-	return methodHandler{in, out, fn}, nil
+	return methodHandler{in: in, out: out, fn: fn}, nil
+}
+
+func (h *methodHandler) setParamNames(names []string) error {
+	if len(names) != len(h.in)-1 {
+		return ErrParamNamesMismatch
+	}
+	h.paramNames = names
+	return nil
+}
+
+// isSubscription returns true if function returns receive channel, such methods are served over WebSocket only
+func (h methodHandler) isSubscription() bool {
+	return len(h.out) == 2 && h.out[0].Kind() == reflect.Chan && h.out[0].ChanDir()&reflect.RecvDir != 0
+}
+
+// acceptsObjectParams returns true if method has exactly one argument (excluding context) that is a struct
+func (h methodHandler) acceptsObjectParams() bool {
+	if len(h.in) != 2 {
+		return false
+	}
+	argType := h.in[1]
+	if argType.Kind() == reflect.Pointer {
+		argType = argType.Elem()
+	}
+	return argType.Kind() == reflect.Struct
+}
+
+// positionalParams converts params of the request to the array of positional params.
+// Object params are accepted for methods with param names and for methods with one struct argument,
+// in the latter case the whole object is used as this argument.
+func (h methodHandler) positionalParams(params json.RawMessage) ([]json.RawMessage, error) {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		return nil, nil
+	}
+
+	switch params[0] {
+	case '[':
+		var result []json.RawMessage
+		if err := json.Unmarshal(params, &result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	case '{':
+		if h.paramNames != nil {
+			var named map[string]json.RawMessage
+			if err := json.Unmarshal(params, &named); err != nil {
+				return nil, err
+			}
+			for name := range named {
+				if !slices.Contains(h.paramNames, name) {
+					return nil, fmt.Errorf("%w: %s", ErrUnknownParam, name)
+				}
+			}
+			result := make([]json.RawMessage, len(h.paramNames))
+			for i, name := range h.paramNames {
+				value, ok := named[name]
+				if !ok {
+					// missing params are set to zero values
+					value = json.RawMessage("null")
+				}
+				result[i] = value
+			}
+			return result, nil
+		}
+		if h.acceptsObjectParams() {
+			return []json.RawMessage{params}, nil
+		}
+		return nil, ErrObjectParamsNotSupported
+	default:
+		return nil, ErrInvalidParams
+	}
 }
 
 func (h methodHandler) call(ctx context.Context, params []json.RawMessage) (any, error) {
 	args, err := extractArgumentsFromJSONparamsArray(h.in[1:], params)
 	if err != nil {
-		return nil, err
+		return nil, NewInvalidParamsError(err)
 	}
 
 	// prepend context.Context
@@ -221,3 +221,102 @@ func TestCall(t *testing.T) {
 		})
 	}
 }
+
+func TestPositionalParams(t *testing.T) {
+	funcWithStruct := func(context.Context, dummyStruct) error {
+		return nil
+	}
+	funcWithStructPointer := func(context.Context, *dummyStruct) error {
+		return nil
+	}
+	funcWithArgs := func(context.Context, int, string) error {
+		return nil
+	}
+
+	testCases := map[string]struct {
+		function       interface{}
+		paramNames     []string
+		params         string
+		expectedParams []json.RawMessage
+		expectedError  error
+	}{
+		"array params": {
+			function:       funcWithArgs,
+			params:         `[1, "a"]`,
+			expectedParams: rawParams(`[1, "a"]`),
+		},
+		"no params": {
+			function:       funcWithArgs,
+			params:         ``,
+			expectedParams: nil,
+		},
+		"null params": {
+			function:       funcWithArgs,
+			params:         `null`,
+			expectedParams: nil,
+		},
+		"struct object params": {
+			function:       funcWithStruct,
+			params:         `{"field": 1}`,
+			expectedParams: rawParams(`[{"field": 1}]`),
+		},
+		"struct pointer object params": {
+			function:       funcWithStructPointer,
+			params:         `{"field": 1}`,
+			expectedParams: rawParams(`[{"field": 1}]`),
+		},
+		"named object params": {
+			function:       funcWithArgs,
+			paramNames:     []string{"number", "text"},
+			params:         `{"text": "a", "number": 1}`,
+			expectedParams: rawParams(`[1, "a"]`),
+		},
+		"named object params with missing param": {
+			function:       funcWithArgs,
+			paramNames:     []string{"number", "text"},
+			params:         `{"text": "a"}`,
+			expectedParams: rawParams(`[null, "a"]`),
+		},
+		"named object params with unknown param": {
+			function:      funcWithArgs,
+			paramNames:    []string{"number", "text"},
+			params:        `{"other": "a"}`,
+			expectedError: ErrUnknownParam,
+		},
+		"object params not supported": {
+			function:      funcWithArgs,
+			params:        `{"number": 1}`,
+			expectedError: ErrObjectParamsNotSupported,
+		},
+		"primitive params": {
+			function:      funcWithArgs,
+			params:        `1`,
+			expectedError: ErrInvalidParams,
+		},
+	}
+
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			methodTypes, err := getMethodTypes(testCase.function)
+			require.NoError(t, err)
+			if testCase.paramNames != nil {
+				require.NoError(t, methodTypes.setParamNames(testCase.paramNames))
+			}
+
+			params, err := methodTypes.positionalParams(json.RawMessage(testCase.params))
+			if testCase.expectedError != nil {
+				require.ErrorIs(t, err, testCase.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, len(testCase.expectedParams), len(params))
+			for i := range params {
+				require.JSONEq(t, string(testCase.expectedParams[i]), string(params[i]))
+			}
+		})
+	}
+
+	methodTypes, err := getMethodTypes(funcWithArgs)
+	require.NoError(t, err)
+	require.ErrorIs(t, methodTypes.setParamNames([]string{"number"}), ErrParamNamesMismatch)
+}
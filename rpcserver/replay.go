@@ -0,0 +1,130 @@
+package rpcserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// CaptureReader reads records written by FileCaptureSink in order
+type CaptureReader struct {
+	decoder *json.Decoder
+}
+
+func NewCaptureReader(r io.Reader) *CaptureReader {
+	return &CaptureReader{decoder: json.NewDecoder(r)}
+}
+
+// Next returns the next record, io.EOF is returned after the last record
+func (r *CaptureReader) Next() (*CaptureRecord, error) {
+	var record CaptureRecord
+	if err := r.decoder.Decode(&record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// ReplayTarget executes captured request and returns raw response
+type ReplayTarget interface {
+	Replay(ctx context.Context, record *CaptureRecord) ([]byte, error)
+}
+
+// HandlerReplayTarget replays captured requests into the http.Handler (e.g. JSONRPCHandler) in process.
+// Captured headers are sent with the request so captured signatures stay valid.
+type HandlerReplayTarget struct {
+	Handler http.Handler
+}
+
+func (t *HandlerReplayTarget) Replay(ctx context.Context, record *CaptureRecord) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", bytes.NewReader(record.Body()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for header, value := range record.Headers {
+		req.Header.Set(header, value)
+	}
+	if record.RemoteAddr != "" {
+		req.RemoteAddr = record.RemoteAddr
+	}
+
+	w := &replayResponseWriter{header: make(http.Header)}
+	t.Handler.ServeHTTP(w, req)
+	return w.body.Bytes(), nil
+}
+
+// replayResponseWriter buffers the response of the handler
+type replayResponseWriter struct {
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *replayResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *replayResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *replayResponseWriter) WriteHeader(int) {}
+
+type ReplayOpts struct {
+	// If true delays between captured requests are preserved, otherwise requests are sent one after another
+	PreserveTiming bool
+	// Speed up factor for PreserveTiming, e.g. 2 replays twice as fast, 1 is used if 0
+	Speed float64
+	// Called after every replayed record with the response or the error of the target
+	OnResponse func(record *CaptureRecord, response []byte, err error)
+}
+
+// Replay sends all records from the reader to the target sequentially in the captured order.
+// Errors of the target are passed to OnResponse, replay stops on context cancellation or read error.
+func Replay(ctx context.Context, reader *CaptureReader, target ReplayTarget, opts ReplayOpts) error {
+	speed := opts.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+
+	var (
+		firstRecordTime time.Time
+		replayStartAt   time.Time
+	)
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if opts.PreserveTiming {
+			if replayStartAt.IsZero() {
+				firstRecordTime, replayStartAt = record.Time, time.Now()
+			}
+			offset := time.Duration(float64(record.Time.Sub(firstRecordTime)) / speed)
+			if wait := time.Until(replayStartAt.Add(offset)); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		response, err := target.Replay(ctx, record)
+		if opts.OnResponse != nil {
+			opts.OnResponse(record, response, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package rpcserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func captureLog(t *testing.T, records ...*CaptureRecord) *CaptureReader {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		require.NoError(t, err)
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return NewCaptureReader(&buf)
+}
+
+func TestReplay(t *testing.T) {
+	now := time.Now()
+	records := []*CaptureRecord{
+		{Time: now, Request: []byte(`{"jsonrpc":"2.0","id":1,"method":"function","params":[1]}`)},
+		{Time: now.Add(50 * time.Millisecond), Request: []byte(`[{"jsonrpc":"2.0","id":2,"method":"function","params":[2]},{"jsonrpc":"2.0","id":"3","method":"function","params":[3]}]`)},
+		{Time: now.Add(100 * time.Millisecond), RawRequest: `{"jsonrpc":`},
+	}
+
+	handler := testHandler(JSONRPCHandlerOpts{}, nil)
+
+	testCases := map[string]struct {
+		target            ReplayTarget
+		opts              ReplayOpts
+		expectedResponses []string
+		expectedErrors    int
+		minDuration       time.Duration
+	}{
+		"handler": {
+			target: &HandlerReplayTarget{Handler: handler},
+			expectedResponses: []string{
+				`{"jsonrpc":"2.0","id":1,"result":{"field":1}}`,
+				`[{"jsonrpc":"2.0","id":2,"result":{"field":2}},{"jsonrpc":"2.0","id":"3","result":{"field":3}}]`,
+				`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"unexpected end of JSON input"}}`,
+			},
+		},
+		"handler with timing": {
+			target: &HandlerReplayTarget{Handler: handler},
+			opts:   ReplayOpts{PreserveTiming: true, Speed: 2},
+			expectedResponses: []string{
+				`{"jsonrpc":"2.0","id":1,"result":{"field":1}}`,
+				`[{"jsonrpc":"2.0","id":2,"result":{"field":2}},{"jsonrpc":"2.0","id":"3","result":{"field":3}}]`,
+				`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"unexpected end of JSON input"}}`,
+			},
+			minDuration: 50 * time.Millisecond,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var (
+				responses []string
+				errors    int
+			)
+			testCase.opts.OnResponse = func(record *CaptureRecord, response []byte, err error) {
+				if err != nil {
+					errors++
+					return
+				}
+				responses = append(responses, string(response))
+			}
+
+			startAt := time.Now()
+			err := Replay(context.Background(), captureLog(t, records...), testCase.target, testCase.opts)
+			require.NoError(t, err)
+			require.GreaterOrEqual(t, time.Since(startAt), testCase.minDuration)
+
+			require.Equal(t, testCase.expectedErrors, errors)
+			require.Len(t, responses, len(testCase.expectedResponses))
+			for i, expected := range testCase.expectedResponses {
+				require.JSONEq(t, expected, responses[i])
+			}
+		})
+	}
+}
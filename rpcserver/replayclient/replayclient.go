@@ -0,0 +1,100 @@
+// Package replayclient replays requests captured by rpcserver to the remote endpoint using rpcclient
+package replayclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/flashbots/go-utils/rpcclient"
+	"github.com/flashbots/go-utils/rpcserver"
+)
+
+var ErrUnsupportedRequest = errors.New("request can't be replayed with rpcclient")
+
+var _ rpcserver.ReplayTarget = (*Target)(nil)
+
+// Target replays captured requests to the remote endpoint using rpcclient.
+// Requests are re-encoded, so the client signs them with its own signer if configured.
+// rpcclient supports only integer request ids, records with other ids or notifications
+// fail with ErrUnsupportedRequest, use rpcserver.HandlerReplayTarget to replay them.
+type Target struct {
+	Client rpcclient.RPCClient
+}
+
+func (t *Target) Replay(ctx context.Context, record *rpcserver.CaptureRecord) ([]byte, error) {
+	if record.Request == nil {
+		return nil, errors.New("captured request is not valid JSON")
+	}
+
+	if body := bytes.TrimSpace(record.Request); len(body) > 0 && body[0] == '[' {
+		var capturedRequests []capturedRequest
+		if err := decodeCapturedRequest(body, &capturedRequests); err != nil {
+			return nil, err
+		}
+		requests := make(rpcclient.RPCRequests, 0, len(capturedRequests))
+		for _, req := range capturedRequests {
+			request, err := req.rpcRequest()
+			if err != nil {
+				return nil, err
+			}
+			requests = append(requests, request)
+		}
+		responses, err := t.Client.CallBatchRaw(ctx, requests)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(responses)
+	}
+
+	var req capturedRequest
+	if err := decodeCapturedRequest(record.Request, &req); err != nil {
+		return nil, err
+	}
+	request, err := req.rpcRequest()
+	if err != nil {
+		return nil, err
+	}
+	response, err := t.Client.CallRaw(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(response)
+}
+
+type capturedRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+func decodeCapturedRequest(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func (r *capturedRequest) rpcRequest() (*rpcclient.RPCRequest, error) {
+	if r.ID == nil {
+		return nil, fmt.Errorf("%w: notification %s", ErrUnsupportedRequest, r.Method)
+	}
+	var id int
+	if err := json.Unmarshal(r.ID, &id); err != nil || bytes.Equal(r.ID, []byte("null")) {
+		return nil, fmt.Errorf("%w: non-integer id %s", ErrUnsupportedRequest, r.ID)
+	}
+	req := &rpcclient.RPCRequest{
+		JSONRPC: r.JSONRPC,
+		ID:      id,
+		Method:  r.Method,
+	}
+	if req.JSONRPC == "" {
+		req.JSONRPC = "2.0"
+	}
+	if len(r.Params) > 0 {
+		req.Params = r.Params
+	}
+	return req, nil
+}
@@ -0,0 +1,91 @@
+package replayclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flashbots/go-utils/rpcclient"
+	"github.com/flashbots/go-utils/rpcserver"
+	"github.com/stretchr/testify/require"
+)
+
+type dummyStruct struct {
+	Field int `json:"field"`
+}
+
+func TestTarget(t *testing.T) {
+	now := time.Now()
+	records := []*rpcserver.CaptureRecord{
+		{Time: now, Request: []byte(`{"jsonrpc":"2.0","id":1,"method":"function","params":[1]}`)},
+		{Time: now, Request: []byte(`[{"jsonrpc":"2.0","id":2,"method":"function","params":[2]},{"jsonrpc":"2.0","id":"3","method":"function","params":[3]}]`)},
+		{Time: now, RawRequest: `{"jsonrpc":`},
+	}
+	var buf bytes.Buffer
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		require.NoError(t, err)
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	handler, err := rpcserver.NewJSONRPCHandler(rpcserver.Methods{
+		"function": func(ctx context.Context, arg int) (dummyStruct, error) {
+			return dummyStruct{arg}, nil
+		},
+	}, rpcserver.JSONRPCHandlerOpts{}, nil)
+	require.NoError(t, err)
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+
+	var (
+		responses []string
+		errs      []error
+	)
+	err = rpcserver.Replay(context.Background(), rpcserver.NewCaptureReader(&buf), &Target{Client: rpcclient.NewClient(httpServer.URL)}, rpcserver.ReplayOpts{
+		OnResponse: func(record *rpcserver.CaptureRecord, response []byte, err error) {
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			responses = append(responses, string(response))
+		},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, responses, 1)
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":{"field":1}}`, responses[0])
+	// batch with string id and invalid request
+	require.Len(t, errs, 2)
+	require.ErrorIs(t, errs[0], ErrUnsupportedRequest)
+}
+
+func TestCapturedRequest_RPCRequest(t *testing.T) {
+	testCases := map[string]struct {
+		request string
+		id      int
+		err     bool
+	}{
+		"integer id":   {request: `{"jsonrpc":"2.0","id":5,"method":"function"}`, id: 5},
+		"string id":    {request: `{"jsonrpc":"2.0","id":"5","method":"function"}`, err: true},
+		"null id":      {request: `{"jsonrpc":"2.0","id":null,"method":"function"}`, err: true},
+		"fraction id":  {request: `{"jsonrpc":"2.0","id":1.5,"method":"function"}`, err: true},
+		"notification": {request: `{"jsonrpc":"2.0","method":"function"}`, err: true},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var captured capturedRequest
+			require.NoError(t, decodeCapturedRequest([]byte(testCase.request), &captured))
+			request, err := captured.rpcRequest()
+			if testCase.err {
+				require.ErrorIs(t, err, ErrUnsupportedRequest)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, testCase.id, request.ID)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package rpcserver
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrEmptyNamespace          = errors.New("namespace must not be empty")
+	ErrNoServiceMethods        = errors.New("service has no suitable methods")
+	ErrMethodAlreadyRegistered = errors.New("method is already registered")
+	ErrNilService              = errors.New("service must not be nil")
+)
+
+// RegisterService registers all exported methods of the receiver that are suitable JSON-RPC methods
+// (see NewJSONRPCHandler) as "namespace_methodName", where method name has the first letter lowercased,
+// e.g. method SendBundle registered in "eth" namespace is served as "eth_sendBundle".
+// Methods that don't have suitable signature are skipped, same as in go-ethereum rpc.Server.
+// Method options passed to NewJSONRPCHandler are applied using the full method name.
+//
+// RegisterService must be called before handler starts serving requests.
+// If error is returned none of the service methods is registered.
+func (h *JSONRPCHandler) RegisterService(namespace string, receiver any) error {
+	if namespace == "" {
+		return ErrEmptyNamespace
+	}
+
+	receiverValue := reflect.ValueOf(receiver)
+	if !receiverValue.IsValid() || (receiverValue.Kind() == reflect.Pointer && receiverValue.IsNil()) {
+		return ErrNilService
+	}
+	receiverType := receiverValue.Type()
+
+	methods := make(map[string]methodHandler)
+	for i := 0; i < receiverType.NumMethod(); i++ {
+		typeMethod := receiverType.Method(i)
+		if !typeMethod.IsExported() {
+			continue
+		}
+		method, err := getMethodTypes(receiverValue.Method(i).Interface())
+		if err != nil {
+			continue
+		}
+
+		methods[namespace+"_"+formatMethodName(typeMethod.Name)] = method
+	}
+	if len(methods) == 0 {
+		return fmt.Errorf("%w: %s", ErrNoServiceMethods, receiverType)
+	}
+
+	// all methods are validated before any of them is registered
+	registrations := make([]*methodRegistration, 0, len(methods))
+	registered := make(map[methodKey]bool)
+	for _, name := range slices.Sorted(maps.Keys(methods)) {
+		registration, err := h.newMethodRegistration(name, methods[name], h.methodOpts[name])
+		if err != nil {
+			return err
+		}
+		// aliases of the service methods can conflict with each other
+		for _, key := range registration.keys() {
+			if registered[key] {
+				return fmt.Errorf("%w: %s", ErrMethodAlreadyRegistered, key.name)
+			}
+			registered[key] = true
+		}
+		registrations = append(registrations, registration)
+	}
+
+	for _, registration := range registrations {
+		h.register(registration)
+	}
+	return nil
+}
+
+// formatMethodName converts name of the exported method to lower camel case used by go-ethereum
+func formatMethodName(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[size:]
+}
@@ -0,0 +1,84 @@
+package rpcserver
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flashbots/go-utils/rpcclient"
+	"github.com/stretchr/testify/require"
+)
+
+type testService struct {
+	calls int
+}
+
+func (s *testService) SendBundle(ctx context.Context, arg dummyStruct) (int, error) {
+	s.calls++
+	return arg.Field, nil
+}
+
+func (s *testService) GetHTTPStatus(ctx context.Context) (string, error) {
+	return "ok", nil
+}
+
+// not suitable JSON-RPC method
+func (s *testService) Calls() int {
+	return s.calls
+}
+
+func TestFormatMethodName(t *testing.T) {
+	require.Equal(t, "sendBundle", formatMethodName("SendBundle"))
+	require.Equal(t, "getHTTPStatus", formatMethodName("GetHTTPStatus"))
+	require.Equal(t, "a", formatMethodName("A"))
+}
+
+func TestRegisterService(t *testing.T) {
+	handler, err := NewJSONRPCHandler(nil, JSONRPCHandlerOpts{}, map[string]MethodOpts{
+		"eth_sendBundle": {ParamNames: []string{"bundle"}},
+	})
+	require.NoError(t, err)
+
+	service := &testService{}
+	require.NoError(t, handler.RegisterService("eth", service))
+	require.Len(t, handler.methods[anyPath], 2)
+	require.Equal(t, []string{"bundle"}, handler.methods[anyPath]["eth_sendBundle"].paramNames)
+
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+	client := rpcclient.NewClient(httpServer.URL)
+
+	var result int
+	require.NoError(t, client.CallFor(context.Background(), &result, "eth_sendBundle", dummyStruct{Field: 3}))
+	require.Equal(t, 3, result)
+	require.Equal(t, 1, service.Calls())
+
+	var status string
+	require.NoError(t, client.CallFor(context.Background(), &status, "eth_getHTTPStatus"))
+	require.Equal(t, "ok", status)
+
+	require.ErrorIs(t, handler.RegisterService("eth", service), ErrMethodAlreadyRegistered)
+	require.ErrorIs(t, handler.RegisterService("", service), ErrEmptyNamespace)
+	require.ErrorIs(t, handler.RegisterService("test", struct{}{}), ErrNoServiceMethods)
+	require.ErrorIs(t, handler.RegisterService("test", nil), ErrNilService)
+	require.ErrorIs(t, handler.RegisterService("test", (*testService)(nil)), ErrNilService)
+}
+
+func TestRegisterService_NoPartialRegistration(t *testing.T) {
+	// invalid options of one method must not leave other methods registered
+	handler, err := NewJSONRPCHandler(nil, JSONRPCHandlerOpts{}, map[string]MethodOpts{
+		"eth_getHTTPStatus": {RateLimit: &RateLimitOpts{RequestsPerSecond: 1}},
+	})
+	require.NoError(t, err)
+	require.Error(t, handler.RegisterService("eth", &testService{}))
+	require.Empty(t, handler.methods)
+
+	// aliases of the service methods conflict with each other
+	handler, err = NewJSONRPCHandler(nil, JSONRPCHandlerOpts{}, map[string]MethodOpts{
+		"eth_getHTTPStatus": {Aliases: []string{"status"}},
+		"eth_sendBundle":    {Aliases: []string{"status"}},
+	})
+	require.NoError(t, err)
+	require.ErrorIs(t, handler.RegisterService("eth", &testService{}), ErrMethodAlreadyRegistered)
+	require.Empty(t, handler.methods)
+}
@@ -0,0 +1,85 @@
+package rpcserver
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const tracerName = "github.com/flashbots/go-utils/rpcserver"
+
+// TracingOpts enables OpenTelemetry span for every JSON-RPC call
+type TracingOpts struct {
+	// otel.GetTracerProvider() is used if nil
+	TracerProvider trace.TracerProvider
+	// Propagator used to extract parent span from the request headers, W3C trace context is used if nil
+	Propagator propagation.TextMapPropagator
+}
+
+type tracer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+func newTracer(opts *TracingOpts) *tracer {
+	if opts == nil {
+		return nil
+	}
+	provider := opts.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	propagator := opts.Propagator
+	if propagator == nil {
+		propagator = propagation.TraceContext{}
+	}
+	return &tracer{
+		tracer:     provider.Tracer(tracerName),
+		propagator: propagator,
+	}
+}
+
+// startSpan starts server span that is a child of the span from the request headers.
+// Span is named unknownMethodLabel until the method is resolved.
+func (t *tracer) startSpan(ctx context.Context, r *httpRequest) (context.Context, trace.Span) {
+	if t == nil {
+		return ctx, noop.Span{}
+	}
+	ctx = t.propagator.Extract(ctx, propagation.HeaderCarrier(r.Header))
+	return t.tracer.Start(ctx, unknownMethodLabel,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("rpc.system", "jsonrpc")),
+	)
+}
+
+// endSpan records values extracted from the request and the result of the call
+func endSpan(ctx context.Context, span trace.Span, res *jsonRPCResponse) {
+	if !span.IsRecording() {
+		span.End()
+		return
+	}
+
+	if signer := GetSigner(ctx); signer != (common.Address{}) {
+		span.SetAttributes(attribute.String("flashbots.signer", signer.Hex()))
+	}
+	if origin := GetOrigin(ctx); origin != "" {
+		span.SetAttributes(attribute.String("flashbots.origin", origin))
+	}
+	if highPriority, ok := ctx.Value(highPriorityKey{}).(bool); ok {
+		span.SetAttributes(attribute.Bool("flashbots.high_priority", highPriority))
+	}
+	if res.Error != nil {
+		span.SetAttributes(
+			attribute.Int("rpc.jsonrpc.error_code", res.Error.Code),
+			attribute.String("rpc.jsonrpc.error_message", res.Error.Message),
+		)
+		span.SetStatus(codes.Error, res.Error.Message)
+	}
+	span.End()
+}
@@ -0,0 +1,89 @@
+package rpcserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flashbots/go-utils/rpcclient"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attributes := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attributes[kv.Key] = kv.Value
+	}
+	return attributes
+}
+
+func TestHandler_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	var methodSpanContext trace.SpanContext
+	handler, err := NewJSONRPCHandler(map[string]any{
+		"function": func(ctx context.Context, arg int) (int, error) {
+			methodSpanContext = trace.SpanContextFromContext(ctx)
+			return arg, nil
+		},
+		"failing": func(ctx context.Context) error {
+			return &JSONRPCError{Code: -32042, Message: "failed"}
+		},
+	}, JSONRPCHandlerOpts{
+		Tracing: &TracingOpts{TracerProvider: provider},
+	}, map[string]MethodOpts{
+		"function": {ExtractOriginFromHeader: true, ExtractPriorityFromHeader: true},
+	})
+	require.NoError(t, err)
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+
+	client := rpcclient.NewClientWithOpts(httpServer.URL, &rpcclient.RPCClientOpts{
+		CustomHeaders: map[string]string{"X-Flashbots-Origin": "test-origin", "high_prio": "true"},
+	})
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	var result int
+	err = client.CallFor(ctx, &result, "function", 1)
+	require.NoError(t, err)
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	span := spans[0]
+	require.Equal(t, "function", span.Name())
+	require.Equal(t, trace.SpanKindServer, span.SpanKind())
+	require.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+	require.Equal(t, parent.SpanContext().TraceID(), span.SpanContext().TraceID())
+	require.Equal(t, span.SpanContext().SpanID(), methodSpanContext.SpanID())
+	attributes := spanAttributes(span)
+	require.Equal(t, "function", attributes["rpc.method"].AsString())
+	require.Equal(t, "test-origin", attributes["flashbots.origin"].AsString())
+	require.True(t, attributes["flashbots.high_priority"].AsBool())
+
+	for _, body := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"failing"}`,
+		`{"jsonrpc":"2.0","id":1,"method":"not_registered"}`,
+	} {
+		req, err := http.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	spans = recorder.Ended()
+	require.Len(t, spans, 4)
+	require.Equal(t, "failing", spans[2].Name())
+	require.Equal(t, codes.Error, spans[2].Status().Code)
+	require.Equal(t, int64(-32042), spanAttributes(spans[2])["rpc.jsonrpc.error_code"].AsInt64())
+	// names of unregistered methods are not used as span names
+	require.Equal(t, unknownMethodLabel, spans[3].Name())
+	require.Equal(t, int64(CodeMethodNotFound), spanAttributes(spans[3])["rpc.jsonrpc.error_code"].AsInt64())
+}
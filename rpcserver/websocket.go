@@ -0,0 +1,391 @@
+package rpcserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	subscribeMethodSuffix    = "_subscribe"
+	unsubscribeMethodSuffix  = "_unsubscribe"
+	notificationMethodSuffix = "_subscription"
+
+	DefaultWebSocketPingInterval          = 30 * time.Second
+	DefaultWebSocketMaxConcurrentRequests = 100
+	wsWriteTimeout                        = 10 * time.Second
+
+	errNotificationsUnsupported = "notifications not supported"
+	errNoSubscription           = "subscription method returned no channel"
+)
+
+type wsRequestKey struct{}
+
+// WebSocketOpts configures WebSocket transport of the JSONRPCHandler
+type WebSocketOpts struct {
+	// Allowed values of the Origin header, all origins are allowed if empty
+	AllowedOrigins []string
+	// Interval between pings sent to the client, connection is closed if client does not respond in two intervals.
+	// DefaultWebSocketPingInterval is used if 0
+	PingInterval time.Duration
+	// Max number of requests of one connection that are handled concurrently, next messages are not read
+	// until one of the requests is finished. DefaultWebSocketMaxConcurrentRequests is used if 0
+	MaxConcurrentRequests int
+}
+
+// WebSocketHandler returns http.Handler that serves JSON-RPC over WebSocket using the same methods as JSONRPCHandler.
+//
+// In addition to the regular methods it supports subscriptions. Registered functions that return receive channel, e.g.
+// func NewHeads(context.Context) (<-chan *types.Header, error)
+// registered as "eth_newHeads" are subscription methods that can only be called over WebSocket as
+// {"method": "eth_subscribe", "params": ["newHeads", ...args]}.
+// Response is subscription id and every value received from the channel is sent as
+// {"method": "eth_subscription", "params": {"subscription": id, "result": value}} notification.
+// Subscription is closed when channel is closed, client calls "eth_unsubscribe" with subscription id or connection is closed,
+// context passed to subscription function is cancelled at that moment.
+//
+// Headers (e.g. x-flashbots-origin) are extracted from the upgrade request.
+// Note that X-Flashbots-Signature can't be verified because it is a signature of the request body.
+func (h *JSONRPCHandler) WebSocketHandler(opts WebSocketOpts) http.Handler {
+	if opts.PingInterval == 0 {
+		opts.PingInterval = DefaultWebSocketPingInterval
+	}
+	if opts.MaxConcurrentRequests == 0 {
+		opts.MaxConcurrentRequests = DefaultWebSocketMaxConcurrentRequests
+	}
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return len(opts.AllowedOrigins) == 0 || origin == "" || slices.Contains(opts.AllowedOrigins, origin)
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// upgrader responds with http error
+			incIncorrectRequest(h.ServerName)
+			return
+		}
+		h.serveWebSocket(r, conn, opts)
+	})
+}
+
+type wsConn struct {
+	conn *websocket.Conn
+
+	writeMu sync.Mutex
+
+	subsMu sync.Mutex
+	subs   map[string]*wsConnSubscription
+}
+
+type wsConnSubscription struct {
+	namespace string
+	cancel    context.CancelFunc
+}
+
+func (c *wsConn) writeJSON(v any) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+		return err
+	}
+	return c.conn.WriteJSON(v)
+}
+
+func (c *wsConn) writePing() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+}
+
+// unsubscribe cancels subscription with the id if it belongs to the namespace
+func (c *wsConn) unsubscribe(namespace, id string) bool {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+
+	sub, ok := c.subs[id]
+	if !ok || sub.namespace != namespace {
+		return false
+	}
+	sub.cancel()
+	delete(c.subs, id)
+	return true
+}
+
+func (h *JSONRPCHandler) serveWebSocket(r *http.Request, conn *websocket.Conn, opts WebSocketOpts) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	defer conn.Close()
+
+	c := &wsConn{
+		conn: conn,
+		subs: make(map[string]*wsConnSubscription),
+	}
+
+	conn.SetReadLimit(h.MaxRequestBodySizeBytes)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * opts.PingInterval))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * opts.PingInterval))
+	})
+
+	go func() {
+		ticker := time.NewTicker(opts.PingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := c.writePing(); err != nil {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	defer func() {
+		// in-flight requests are cancelled before waiting for them
+		cancel()
+		wg.Wait()
+	}()
+	inFlight := make(chan struct{}, opts.MaxConcurrentRequests)
+	for {
+		// next message is not read until there is a free slot
+		select {
+		case inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		// pongs are not read while waiting for the slot
+		_ = conn.SetReadDeadline(time.Now().Add(2 * opts.PingInterval))
+
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(2 * opts.PingInterval))
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-inFlight
+				wg.Done()
+			}()
+			h.handleWebSocketMessage(ctx, r, c, message)
+		}()
+	}
+}
+
+func (h *JSONRPCHandler) handleWebSocketMessage(ctx context.Context, r *http.Request, c *wsConn, message []byte) {
+	wsReq := &wsRequest{conn: c}
+	ctx = context.WithValue(ctx, wsRequestKey{}, wsReq)
+
+	res, isNotification := h.handleBody(ctx, &httpRequest{Request: r, body: message}, time.Now())
+	if res != nil && !isNotification {
+		if err := c.writeJSON(res); err != nil {
+			if h.Log != nil {
+				h.Log.Debug("failed to write websocket response", slog.Any("error", err), slog.String("serverName", h.ServerName))
+			}
+			wsReq.cancelSubscriptions()
+			return
+		}
+	}
+
+	// subscriptions are started after response with subscription id is sent
+	wsReq.startSubscriptions()
+}
+
+// wsRequest is a message received over WebSocket, it is stored in the context of the request
+type wsRequest struct {
+	conn *wsConn
+
+	mu   sync.Mutex
+	subs []*wsSubscription
+}
+
+type wsSubscription struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	id        string
+	namespace string
+	ch        reflect.Value
+}
+
+func wsRequestFromContext(ctx context.Context) *wsRequest {
+	value, _ := ctx.Value(wsRequestKey{}).(*wsRequest)
+	return value
+}
+
+// subscribe calls subscription method and subscribes to the returned channel, returns subscription id
+func (r *wsRequest) subscribe(ctx context.Context, namespace string, call func(context.Context) (any, error)) (any, error) {
+	// subscription lives until it is cancelled or connection is closed
+	ctx, cancel := context.WithCancel(ctx)
+	result, err := call(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	ch := reflect.ValueOf(result)
+	if !ch.IsValid() || ch.IsNil() {
+		cancel()
+		return nil, &JSONRPCError{Code: CodeInternalError, Message: errNoSubscription}
+	}
+	return r.addSubscription(ctx, cancel, namespace, ch), nil
+}
+
+// addSubscription registers the subscription that is started after the response is sent, returns subscription id
+func (r *wsRequest) addSubscription(ctx context.Context, cancel context.CancelFunc, namespace string, ch reflect.Value) string {
+	var idBytes [16]byte
+	_, _ = rand.Read(idBytes[:])
+	id := hexutil.Encode(idBytes[:])
+
+	r.conn.subsMu.Lock()
+	r.conn.subs[id] = &wsConnSubscription{namespace: namespace, cancel: cancel}
+	r.conn.subsMu.Unlock()
+
+	r.mu.Lock()
+	r.subs = append(r.subs, &wsSubscription{ctx: ctx, cancel: cancel, id: id, namespace: namespace, ch: ch})
+	r.mu.Unlock()
+	return id
+}
+
+func (r *wsRequest) startSubscriptions() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, sub := range r.subs {
+		go r.conn.forwardSubscription(sub)
+	}
+	r.subs = nil
+}
+
+func (r *wsRequest) cancelSubscriptions() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, sub := range r.subs {
+		r.conn.unsubscribe(sub.namespace, sub.id)
+	}
+	r.subs = nil
+}
+
+type wsNotification struct {
+	JSONRPC string               `json:"jsonrpc"`
+	Method  string               `json:"method"`
+	Params  wsNotificationParams `json:"params"`
+}
+
+type wsNotificationParams struct {
+	Subscription string `json:"subscription"`
+	Result       any    `json:"result"`
+}
+
+// forwardSubscription sends values received from the subscription channel as notifications until it is closed or cancelled
+func (c *wsConn) forwardSubscription(sub *wsSubscription) {
+	defer c.unsubscribe(sub.namespace, sub.id)
+
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(sub.ctx.Done())},
+		{Dir: reflect.SelectRecv, Chan: sub.ch},
+	}
+	for {
+		chosen, value, ok := reflect.Select(cases)
+		if chosen == 0 || !ok {
+			return
+		}
+		notification := wsNotification{
+			JSONRPC: "2.0",
+			Method:  sub.namespace + notificationMethodSuffix,
+			Params: wsNotificationParams{
+				Subscription: sub.id,
+				Result:       value.Interface(),
+			},
+		}
+		if err := c.writeJSON(notification); err != nil {
+			return
+		}
+	}
+}
+
+// resolveSubscription finds subscription method for the <namespace>_subscribe request and rewrites request to call it.
+// Returns namespace of the subscription.
+func (h *JSONRPCHandler) resolveSubscription(path string, req *jsonRPCRequest) (methodConfig, string, bool) {
+	namespace, ok := strings.CutSuffix(req.Method, subscribeMethodSuffix)
+	if !ok {
+		return methodConfig{}, "", false
+	}
+
+	var params []json.RawMessage
+	if err := json.Unmarshal(req.Params, &params); err != nil || len(params) == 0 {
+		return methodConfig{}, "", false
+	}
+	var name string
+	if err := json.Unmarshal(params[0], &name); err != nil {
+		return methodConfig{}, "", false
+	}
+
+	method := namespace + "_" + name
+	config, ok := h.lookupMethod(path, method)
+	if !ok || !config.isSubscription() {
+		return methodConfig{}, "", false
+	}
+
+	req.Method = method
+	req.Params, _ = json.Marshal(params[1:])
+	return config, namespace, true
+}
+
+// handleUnsubscribe handles <namespace>_unsubscribe request over WebSocket, returns false if request is not unsubscribe
+// or there are no subscription methods in the namespace. Only subscriptions of the namespace can be cancelled.
+func (h *JSONRPCHandler) handleUnsubscribe(ctx context.Context, path string, req *jsonRPCRequest) (jsonRPCResponse, bool) {
+	wsReq := wsRequestFromContext(ctx)
+	if wsReq == nil {
+		return jsonRPCResponse{}, false
+	}
+	namespace, ok := strings.CutSuffix(req.Method, unsubscribeMethodSuffix)
+	if !ok || !h.hasSubscriptions(path, namespace) {
+		return jsonRPCResponse{}, false
+	}
+
+	var params []string
+	if err := json.Unmarshal(req.Params, &params); err != nil || len(params) != 1 {
+		return newJSONRPCErrorResponse(req.ID, CodeInvalidParams, "expected subscription id"), true
+	}
+
+	result := json.RawMessage("false")
+	if wsReq.conn.unsubscribe(namespace, params[0]) {
+		result = json.RawMessage("true")
+	}
+	return jsonRPCResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result:  &result,
+		Error:   nil,
+	}, true
+}
+
+// hasSubscriptions returns true if there are subscription methods in the namespace available at the path
+func (h *JSONRPCHandler) hasSubscriptions(path, namespace string) bool {
+	for _, methods := range []map[string]methodConfig{h.methods[anyPath], h.methods[normalizePath(path)]} {
+		for name, config := range methods {
+			if config.isSubscription() && strings.HasPrefix(name, namespace+"_") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,243 @@
+package rpcserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func testSubscriptionHandler(t *testing.T) *JSONRPCHandler {
+	t.Helper()
+	handler, err := NewJSONRPCHandler(map[string]any{
+		"test_echo": func(ctx context.Context, arg int) (int, error) {
+			return arg, nil
+		},
+		"test_counter": func(ctx context.Context, from int) (<-chan dummyStruct, error) {
+			ch := make(chan dummyStruct)
+			go func() {
+				defer close(ch)
+				for i := from; ; i++ {
+					select {
+					case ch <- dummyStruct{i}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			return ch, nil
+		},
+	}, JSONRPCHandlerOpts{}, nil)
+	require.NoError(t, err)
+	return handler
+}
+
+func TestWebSocketHandler(t *testing.T) {
+	handler := testSubscriptionHandler(t)
+	httpServer := httptest.NewServer(handler.WebSocketHandler(WebSocketOpts{}))
+	defer httpServer.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// regular call
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"test_echo","params":[5]}`)))
+	_, message, err := conn.ReadMessage()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":5}`, string(message))
+
+	// subscription method can't be called directly
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"test_counter","params":[1]}`)))
+	_, message, err = conn.ReadMessage()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":2,"error":{"code":-32601,"message":"method not found"}}`, string(message))
+
+	// subscribe
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":3,"method":"test_subscribe","params":["counter",10]}`)))
+	var subResponse struct {
+		ID     int    `json:"id"`
+		Result string `json:"result"`
+	}
+	require.NoError(t, conn.ReadJSON(&subResponse))
+	require.Equal(t, 3, subResponse.ID)
+	require.NotEmpty(t, subResponse.Result)
+
+	var notification struct {
+		Method string `json:"method"`
+		Params struct {
+			Subscription string      `json:"subscription"`
+			Result       dummyStruct `json:"result"`
+		} `json:"params"`
+	}
+	for i := 10; i < 13; i++ {
+		require.NoError(t, conn.ReadJSON(&notification))
+		require.Equal(t, "test_subscription", notification.Method)
+		require.Equal(t, subResponse.Result, notification.Params.Subscription)
+		require.Equal(t, i, notification.Params.Result.Field)
+	}
+
+	// unsubscribe, notifications that were in flight are skipped
+	readResponse := func() string {
+		for {
+			_, message, err := conn.ReadMessage()
+			require.NoError(t, err)
+			var res map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal(message, &res))
+			if _, ok := res["id"]; ok {
+				return string(message)
+			}
+		}
+	}
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":4,"method":"test_unsubscribe","params":["`+subResponse.Result+`"]}`)))
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":4,"result":true}`, readResponse())
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":5,"method":"test_unsubscribe","params":["`+subResponse.Result+`"]}`)))
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":5,"result":false}`, readResponse())
+}
+
+func TestSubscribeOverHTTP(t *testing.T) {
+	handler := testSubscriptionHandler(t)
+
+	body := bytes.NewReader([]byte(`{"jsonrpc":"2.0","id":1,"method":"test_subscribe","params":["counter",10]}`))
+	request, err := http.NewRequest(http.MethodPost, "/", body)
+	require.NoError(t, err)
+	request.Header.Add("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, request)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"notifications not supported"}}`, rr.Body.String())
+}
+
+func TestWebSocketHandler_CancelOnDisconnect(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	handler, err := NewJSONRPCHandler(map[string]any{
+		"test_block": func(ctx context.Context) (int, error) {
+			close(started)
+			<-ctx.Done()
+			close(cancelled)
+			return 0, ctx.Err()
+		},
+	}, JSONRPCHandlerOpts{}, nil)
+	require.NoError(t, err)
+	httpServer := httptest.NewServer(handler.WebSocketHandler(WebSocketOpts{}))
+	defer httpServer.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), nil)
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"test_block"}`)))
+	<-started
+	require.NoError(t, conn.Close())
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("method context is not cancelled after client disconnected")
+	}
+}
+
+func TestWebSocketHandler_MaxConcurrentRequests(t *testing.T) {
+	var (
+		inFlight    atomic.Int32
+		maxInFlight atomic.Int32
+	)
+	release := make(chan struct{})
+	handler, err := NewJSONRPCHandler(map[string]any{
+		"test_block": func(ctx context.Context) (int, error) {
+			current := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				observed := maxInFlight.Load()
+				if current <= observed || maxInFlight.CompareAndSwap(observed, current) {
+					break
+				}
+			}
+			<-release
+			return 1, nil
+		},
+	}, JSONRPCHandlerOpts{}, nil)
+	require.NoError(t, err)
+	httpServer := httptest.NewServer(handler.WebSocketHandler(WebSocketOpts{MaxConcurrentRequests: 2}))
+	defer httpServer.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"test_block"}`)))
+	}
+	require.Eventually(t, func() bool { return inFlight.Load() == 2 }, time.Second, time.Millisecond)
+	// next messages are not read while the limit is reached
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, int32(2), inFlight.Load())
+
+	close(release)
+	for i := 0; i < 5; i++ {
+		_, message, err := conn.ReadMessage()
+		require.NoError(t, err)
+		require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":1}`, string(message))
+	}
+	require.Equal(t, int32(2), maxInFlight.Load())
+}
+
+func TestWebSocketHandler_Unsubscribe(t *testing.T) {
+	counter := func(ctx context.Context) (<-chan int, error) {
+		return make(chan int), nil
+	}
+	handler, err := NewJSONRPCHandler(map[string]any{
+		"test_counter":  counter,
+		"other_counter": counter,
+		"test_nil": func(ctx context.Context) (<-chan int, error) {
+			return nil, nil
+		},
+		"plain_unsubscribe": func(ctx context.Context, id string) (string, error) {
+			return id, nil
+		},
+	}, JSONRPCHandlerOpts{}, nil)
+	require.NoError(t, err)
+	httpServer := httptest.NewServer(handler.WebSocketHandler(WebSocketOpts{}))
+	defer httpServer.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	call := func(request string) string {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(request)))
+		_, message, err := conn.ReadMessage()
+		require.NoError(t, err)
+		return string(message)
+	}
+
+	var subResponse struct {
+		Result string `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(call(`{"jsonrpc":"2.0","id":1,"method":"test_subscribe","params":["counter"]}`)), &subResponse))
+	require.NotEmpty(t, subResponse.Result)
+
+	// subscription of another namespace can't be cancelled
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":2,"result":false}`,
+		call(`{"jsonrpc":"2.0","id":2,"method":"other_unsubscribe","params":["`+subResponse.Result+`"]}`))
+	// methods ending with _unsubscribe are called if there are no subscriptions in the namespace
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":3,"result":"`+subResponse.Result+`"}`,
+		call(`{"jsonrpc":"2.0","id":3,"method":"plain_unsubscribe","params":["`+subResponse.Result+`"]}`))
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":4,"error":{"code":-32601,"message":"method not found"}}`,
+		call(`{"jsonrpc":"2.0","id":4,"method":"unknown_unsubscribe","params":["`+subResponse.Result+`"]}`))
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":5,"result":true}`,
+		call(`{"jsonrpc":"2.0","id":5,"method":"test_unsubscribe","params":["`+subResponse.Result+`"]}`))
+
+	// subscription method must return a channel
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":6,"error":{"code":-32603,"message":"subscription method returned no channel"}}`,
+		call(`{"jsonrpc":"2.0","id":6,"method":"test_subscribe","params":["nil"]}`))
+}